@@ -14,6 +14,7 @@
 package v1beta1
 
 import (
+	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -39,6 +40,9 @@ type VirtualHost struct {
 	// are described in fqdn and aliases, the tls.secretName secret must contain a
 	// matching certificate
 	TLS `json:"tls"`
+	// HeadersPolicy manages the headers of every request to and response from
+	// the virtual host
+	HeadersPolicy `json:",inline"`
 }
 
 // TLS describes tls properties. The CNI names that will be matched on
@@ -47,15 +51,90 @@ type VirtualHost struct {
 type TLS struct {
 	// required, the name of a secret in the current namespace
 	SecretName string `json:"secretName"`
+	// ClientValidation, if present, requires clients to present a certificate
+	// signed by the given CA
+	ClientValidation *ClientValidation `json:"clientValidation,omitempty"`
+}
+
+// ClientValidation defines how to verify the certificate presented by clients
+type ClientValidation struct {
+	// Name of the Kubernetes secret, in the current namespace, holding the
+	// CA certificate used to verify client certificates
+	CACertificate string `json:"caSecret"`
+	// ForwardClientCertificate forwards the details of the client certificate
+	// to the backend in the x-forwarded-client-cert header
+	ForwardClientCertificate bool `json:"forwardClientCertificate,omitempty"`
 }
 
 // Route contains the set of routes for a virtual host
 type Route struct {
-	// Match defines the prefix match
+	// Match defines the path match, a prefix unless MatchType says otherwise
 	Match string `json:"match"`
+	// MatchType is how Match is matched against the request path: prefix,
+	// the default, exact, or regex. Regex matches must match the whole path,
+	// and use the syntax common to ECMAScript and RE2
+	MatchType string `json:"matchType,omitempty"`
+	// Headers are conditions on the request headers, all of which must
+	// be met for the route to match
+	Headers []HeaderCondition `json:"headers,omitempty"`
+	// PrefixRewrite, if set, replaces the matched path prefix, or exact path,
+	// before the request is forwarded. The routes of a delegated IngressRoute
+	// have the part of their match delegated to them rewritten
+	PrefixRewrite string `json:"prefixRewrite,omitempty"`
 	// Service are the services to proxy traffic
 	Services []Service `json:"services"`
 	Delegate `json:"delegate"`
+	// Redirect, if present, redirects requests rather than proxying them to
+	// services. A route which redirects has no services and does not delegate
+	Redirect *Redirect `json:"redirect,omitempty"`
+}
+
+// Redirect defines an HTTP redirect. At least one of Host, Path, or Scheme
+// must be set, the parts of the request URL which are not set are kept
+type Redirect struct {
+	// Host replaces the host of the URL
+	Host string `json:"host,omitempty"`
+	// Path replaces the path of the URL
+	Path string `json:"path,omitempty"`
+	// Scheme replaces the scheme of the URL, only https is supported
+	Scheme string `json:"scheme,omitempty"`
+	// StatusCode is the response code, one of 301, the default, 302, 307, or 308
+	StatusCode int `json:"statusCode,omitempty"`
+}
+
+// HeadersPolicy defines how the headers of a virtual host are managed.
+// Envoy 1.6 cannot remove request headers
+type HeadersPolicy struct {
+	// RequestHeadersToAdd are added to requests before they are forwarded
+	RequestHeadersToAdd []HeaderValue `json:"requestHeadersToAdd,omitempty"`
+	// ResponseHeadersToAdd are added to responses before they are returned
+	ResponseHeadersToAdd []HeaderValue `json:"responseHeadersToAdd,omitempty"`
+	// ResponseHeadersToRemove are removed from responses before they are returned
+	ResponseHeadersToRemove []string `json:"responseHeadersToRemove,omitempty"`
+}
+
+// HeaderValue is a header name and value
+type HeaderValue struct {
+	// Name is the name of the header
+	Name string `json:"name"`
+	// Value is the value of the header
+	Value string `json:"value"`
+}
+
+// HeaderCondition matches a request header. Exactly one of Exact, Regex,
+// or Present must be set
+type HeaderCondition struct {
+	// Name is the name of the header
+	Name string `json:"name"`
+	// Exact matches a header whose value is exactly this string
+	Exact string `json:"exact,omitempty"`
+	// Regex matches a header whose whole value matches this regular expression,
+	// written in the syntax common to ECMAScript and RE2
+	Regex string `json:"regex,omitempty"`
+	// Present matches a header which is present, whatever its value
+	Present bool `json:"present,omitempty"`
+	// Invert inverts the condition, the route matches if the header does not match
+	Invert bool `json:"invert,omitempty"`
 }
 
 // Service defines an upstream to proxy traffic to
@@ -67,6 +146,17 @@ type Service struct {
 	Port int `json:"port"`
 	// Weight defines percentage of traffic to balance traffic
 	Weight *int `json:"weight"`
+	// UpstreamValidation defines how to verify the backend service's certificate
+	UpstreamValidation *UpstreamValidation `json:"validation,omitempty"`
+}
+
+// UpstreamValidation defines how to verify the backend service's certificate
+type UpstreamValidation struct {
+	// Name of the Kubernetes secret, in the current namespace, holding the
+	// CA certificate used to verify the backend certificate
+	CACertificate string `json:"caSecret"`
+	// Key which is expected to be present in the 'subjectAltName' of the presented certificate
+	SubjectName string `json:"subjectName"`
 }
 
 // Delegate allows for passing delgating VHosts to other IngressRoutes
@@ -77,6 +167,17 @@ type Delegate struct {
 	Namespace string `json:"namespace"`
 }
 
+// Status reports the current state of the IngressRoute
+type Status struct {
+	// CurrentStatus is one of valid, invalid, or orphaned
+	CurrentStatus string `json:"currentStatus"`
+	// Description describes the reason for the current status
+	Description string `json:"description"`
+	// LoadBalancer contains the current status of the load-balancer
+	// serving the virtual host of a root IngressRoute
+	LoadBalancer v1.LoadBalancerStatus `json:"loadBalancer,omitempty"`
+}
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
@@ -85,7 +186,8 @@ type IngressRoute struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata"`
 
-	Spec IngressRouteSpec `json:"spec"`
+	Spec   IngressRouteSpec `json:"spec"`
+	Status `json:"status"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
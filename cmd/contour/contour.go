@@ -20,21 +20,30 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/heptio/contour/internal/debug"
 	clientset "github.com/heptio/contour/internal/generated/clientset/versioned"
 	"github.com/heptio/workgroup"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/heptio/contour/internal/contour"
 	"github.com/heptio/contour/internal/envoy"
 	"github.com/heptio/contour/internal/grpc"
+	"github.com/heptio/contour/internal/httpsvc"
 	"github.com/heptio/contour/internal/k8s"
+	"github.com/heptio/contour/internal/metrics"
 
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -55,6 +64,8 @@ func main() {
 	bootstrap.Flag("stats-port", "Envoy /stats interface port").IntVar(&config.StatsPort)
 	bootstrap.Flag("xds-address", "xDS gRPC API address").StringVar(&config.XDSAddress)
 	bootstrap.Flag("xds-port", "xDS gRPC API port").IntVar(&config.XDSGRPCPort)
+	bootstrap.Flag("ads", "Retrieve all resources over the aggregated discovery service").BoolVar(&config.ADS)
+	bootstrap.Flag("load-reporting", "Send load reports to the load reporting service").BoolVar(&config.LoadReporting)
 	bootstrap.Flag("statsd-enabled", "enable statsd output").BoolVar(&config.StatsdEnabled)
 	bootstrap.Flag("statsd-address", "statsd address").StringVar(&config.StatsdAddress)
 	bootstrap.Flag("statsd-port", "statsd port").IntVar(&config.StatsdPort)
@@ -82,12 +93,24 @@ func main() {
 
 	// configuration parameters for debug service
 	debug := debug.Service{
-		FieldLogger: log.WithField("context", "debugsvc"),
+		Service: httpsvc.Service{
+			FieldLogger: log.WithField("context", "debugsvc"),
+		},
 	}
 
 	serve.Flag("debug address", "address the /debug/pprof endpoint will bind too").Default("127.0.0.1").StringVar(&debug.Addr)
 	serve.Flag("debug port", "port the /debug/pprof endpoint will bind too").Default("8000").IntVar(&debug.Port)
 
+	// configuration parameters for metrics service
+	metricsvc := metrics.Service{
+		Service: httpsvc.Service{
+			FieldLogger: log.WithField("context", "metricsvc"),
+		},
+	}
+
+	serve.Flag("metrics-address", "address the /metrics endpoint will bind too").Default("127.0.0.1").StringVar(&metricsvc.Addr)
+	serve.Flag("metrics-port", "port the /metrics endpoint will bind too").Default("8003").IntVar(&metricsvc.Port)
+
 	// translator configuration
 	serve.Flag("envoy-http-access-log", "Envoy HTTP access log").Default(contour.DEFAULT_HTTP_ACCESS_LOG).StringVar(&t.HTTPAccessLog)
 	serve.Flag("envoy-https-access-log", "Envoy HTTPS access log").Default(contour.DEFAULT_HTTPS_ACCESS_LOG).StringVar(&t.HTTPSAccessLog)
@@ -97,6 +120,26 @@ func main() {
 	serve.Flag("envoy-https-port", "Envoy HTTPS listener port").IntVar(&t.HTTPSPort)
 	serve.Flag("use-proxy-protocol", "Use PROXY protocol for all listeners").BoolVar(&t.UseProxyProto)
 	serve.Flag("ingress-class-name", "Contour IngressClass name").StringVar(&t.IngressClass)
+	defaultTLSSecret := serve.Flag("default-tls-secret", "Secret, as namespace/name, whose certificate is served to TLS clients which send no SNI name or an unknown name").String()
+	secretLabelSelector := serve.Flag("secret-label-selector", "Label selector of the Secrets to watch").String()
+	secretFieldSelector := serve.Flag("secret-field-selector", "Field selector of the Secrets to watch").String()
+	tlsSecretsOnly := serve.Flag("tls-secrets-only", "Watch only Secrets of type kubernetes.io/tls").Bool()
+	watchNamespaces := serve.Flag("watch-namespaces", "Comma separated namespaces to watch for Kubernetes objects, may be repeated. The namespaces of the Envoy Service and default TLS Secret are added. If not set, all namespaces are watched").Strings()
+	useADS := serve.Flag("ads", "Direct Envoy to retrieve endpoints and routes over the aggregated discovery service, requires Envoy be bootstrapped with --ads").Bool()
+
+	// status publishing configuration
+	serve.Flag("envoy-service-name", "Name of the Envoy Service whose load balancer status is published to Ingress objects").StringVar(&t.EnvoyServiceName)
+	serve.Flag("envoy-service-namespace", "Namespace of the Envoy Service").Default("heptio-contour").StringVar(&t.EnvoyServiceNamespace)
+	serve.Flag("ingress-status-address", "Static address to publish to Ingress objects, overrides --envoy-service-name").StringVar(&t.StatusAddress)
+
+	// leader election configuration
+	var election leaderElectionConfig
+	enableLeaderElection := serve.Flag("enable-leader-election", "Elect a leader among Contour replicas, only the leader writes Ingress and IngressRoute status").Bool()
+	serve.Flag("leader-election-namespace", "Namespace of the leader election ConfigMap").Default("heptio-contour").StringVar(&election.Namespace)
+	serve.Flag("leader-election-configmap", "Name of the leader election ConfigMap").Default("contour").StringVar(&election.Name)
+	serve.Flag("leader-election-lease-duration", "Duration a leader holds the lease before it may be acquired by another replica").Default("15s").DurationVar(&election.LeaseDuration)
+	serve.Flag("leader-election-renew-deadline", "Duration the leader retries renewing the lease before giving up leadership").Default("10s").DurationVar(&election.RenewDeadline)
+	serve.Flag("leader-election-retry-period", "Interval between attempts to acquire or renew the lease").Default("2s").DurationVar(&election.RetryPeriod)
 
 	args := os.Args[1:]
 	switch kingpin.MustParse(app.Parse(args)) {
@@ -116,6 +159,20 @@ func main() {
 		watchstream(stream, routeType, resources)
 	case serve.FullCommand():
 		log.Infof("args: %v", args)
+		if *defaultTLSSecret != "" {
+			secret, err := k8s.ParseFullName(*defaultTLSSecret)
+			if err != nil {
+				check(fmt.Errorf("--default-tls-secret %v", err))
+			}
+			t.DefaultTLSSecret = secret
+		}
+		_, err := labels.Parse(*secretLabelSelector)
+		check(err)
+		_, err = fields.ParseSelector(*secretFieldSelector)
+		check(err)
+		t.ClusterCache.UseADS = *useADS
+		t.ListenerCache.UseADS = *useADS
+
 		var g workgroup.Group
 
 		// buffer notifications to t to ensure they are handled sequentially.
@@ -129,20 +186,97 @@ func main() {
 		flag.Parse()
 		client, contourClient := newClient(*masterUrl, *kubeconfig, *inCluster)
 
+		// write Ingress and IngressRoute status back to the API server.
+		t.IngressRouteStatus = &k8s.IngressRouteStatus{
+			Client: contourClient,
+		}
+		t.IngressStatus = &k8s.IngressStatus{
+			Client: client,
+		}
+		// hold back status until the caches have synced.
+		t.WaitForSync = true
+
+		if *enableLeaderElection {
+			// only the leader writes status, every replica serves xDS.
+			t.LeaderElection = true
+			startLeaderElection(&g, log, client, election, func() {
+				// publish, in order with other events, any status
+				// which changed while another replica was leader.
+				buf.Do(t.StartedLeading)
+			}, func() {
+				buf.Do(t.StoppedLeading)
+			})
+		}
+
+		// watch, along with the namespaces requested, those holding
+		// the objects Contour reads outside of them.
+		var deps []string
+		if t.EnvoyServiceName != "" {
+			deps = append(deps, t.EnvoyServiceNamespace)
+		}
+		if t.DefaultTLSSecret.Name != "" {
+			deps = append(deps, t.DefaultTLSSecret.Namespace)
+		}
+		namespaces := watchedNamespaces(splitList(*watchNamespaces), deps...)
+		if len(namespaces) > 0 {
+			log.WithField("namespaces", namespaces).Info("watching namespaces")
+		}
 		wl := log.WithField("context", "watch")
-		k8s.WatchServices(&g, client, wl, buf)
-		k8s.WatchIngress(&g, client, wl, buf)
-		k8s.WatchSecrets(&g, client, wl, buf)
-		k8s.WatchIngressRoutes(&g, contourClient, wl, buf)
+		synced := []cache.InformerSynced{
+			k8s.WatchServices(&g, client, wl, namespaces, buf).HasSynced,
+			k8s.WatchIngress(&g, client, wl, namespaces, buf).HasSynced,
+			k8s.WatchSecrets(&g, client, wl, namespaces, *secretLabelSelector, secretFields(*secretFieldSelector, *tlsSecretsOnly), buf).HasSynced,
+			k8s.WatchIngressRoutes(&g, contourClient, wl, namespaces, buf).HasSynced,
+		}
 
 		// Endpoints updates are handled directly by the EndpointsTranslator
 		// due to their high update rate and their orthogonal nature.
 		et := &contour.EndpointsTranslator{
 			FieldLogger: log.WithField("context", "endpointstranslator"),
 		}
-		k8s.WatchEndpoints(&g, client, wl, et)
+		synced = append(synced, k8s.WatchEndpoints(&g, client, wl, namespaces, et).HasSynced)
+
+		// ready is closed once every informer has completed its initial
+		// list and the resulting events have been translated. Until then
+		// the gRPC server holds back its responses and /readyz reports 503.
+		ready := make(chan struct{})
+		g.Add(func(stop <-chan struct{}) error {
+			log := log.WithField("context", "sync")
+			if !cache.WaitForCacheSync(stop, synced...) {
+				return nil
+			}
+			// status was held back until now, write it once.
+			buf.Do(t.Synced)
+			buf.Sync(stop)
+			close(ready)
+			log.Info("caches synced")
+			<-stop
+			return nil
+		})
+		metricsvc.Ready = ready
+
+		// load reports received from Envoy are served by the debug
+		// service and, along with Contour's own metrics, the metrics service.
+		lrs := new(grpc.LoadStats)
+		debug.LoadStats = lrs
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(lrs)
+		m := metrics.NewMetrics(registry)
+		t.Metrics = m
+		m.QueueDepth(buf.Len)
+		m.CacheResources("cluster", count(&t.ClusterCache))
+		m.CacheResources("endpoint", count(et))
+		m.CacheResources("listener", count(&t.ListenerCache))
+		m.CacheResources("route", count(&t.VirtualHostCache.HTTP, &t.VirtualHostCache.HTTPS, &t.VirtualHostCache.ClientValidated))
+		m.CacheNotifications("cluster", t.ClusterCache.Notifications)
+		m.CacheNotifications("endpoint", et.Notifications)
+		m.CacheNotifications("listener", t.ListenerCache.Notifications)
+		m.CacheNotifications("route", t.VirtualHostCache.Notifications)
+		metricsvc.Registry = registry
 
 		g.Add(debug.Start)
+		g.Add(metricsvc.Start)
 
 		g.Add(func(stop <-chan struct{}) error {
 			log := log.WithField("context", "grpc")
@@ -151,7 +285,7 @@ func main() {
 			if err != nil {
 				return err
 			}
-			s := grpc.NewAPI(log, t, et)
+			s := grpc.NewAPI(log, t, et, lrs, m, ready)
 			log.Println("started")
 			defer log.Println("stopped")
 			return s.Serve(l)
@@ -185,6 +319,66 @@ func newClient(masterUrl string, kubeconfig string, inCluster bool) (*kubernetes
 	return client, contourClient
 }
 
+// secretFields returns the field selector of the Secrets to watch,
+// selector, restricted to Secrets of type kubernetes.io/tls if tlsOnly
+// is true.
+func secretFields(selector string, tlsOnly bool) string {
+	if !tlsOnly {
+		return selector
+	}
+	tls := fields.OneTermEqualSelector("type", string(v1.SecretTypeTLS)).String()
+	if selector == "" {
+		return tls
+	}
+	return selector + "," + tls
+}
+
+// splitList splits each of values on commas,
+// returning the non empty elements.
+func splitList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, e := range strings.Split(v, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				list = append(list, e)
+			}
+		}
+	}
+	return list
+}
+
+// watchedNamespaces returns namespaces followed by each of deps not
+// already present. If namespaces is empty every namespace is watched,
+// so watchedNamespaces returns nil.
+func watchedNamespaces(namespaces []string, deps ...string) []string {
+	if len(namespaces) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var watched []string
+	for _, ns := range append(namespaces, deps...) {
+		if !seen[ns] {
+			seen[ns] = true
+			watched = append(watched, ns)
+		}
+	}
+	return watched
+}
+
+// count returns a function which returns the
+// total number of values in caches.
+func count(caches ...interface {
+	Values(func(string) bool) []proto.Message
+}) func() int {
+	return func() int {
+		n := 0
+		for _, c := range caches {
+			n += len(c.Values(func(string) bool { return true }))
+		}
+		return n
+	}
+}
+
 func check(err error) {
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
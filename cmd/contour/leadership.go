@@ -0,0 +1,238 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/heptio/workgroup"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+)
+
+// leaderElectionConfig holds the configuration of leader election
+// between Contour replicas.
+type leaderElectionConfig struct {
+	// Namespace and Name name the ConfigMap used as the lock.
+	Namespace string
+	Name      string
+
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// startLeaderElection adds a leader elector to g. elected is called
+// when this Contour is elected leader, and deposed when it later loses
+// the lease, after which it campaigns again. Losing the lease does not
+// stop g, as only status writes depend on it.
+func startLeaderElection(g *workgroup.Group, log logrus.FieldLogger, client *kubernetes.Clientset, config leaderElectionConfig, elected, deposed func()) {
+	check(config.validate())
+
+	id, err := os.Hostname()
+	check(err)
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: client.CoreV1().Events(config.Namespace),
+	})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "contour"})
+
+	rl, err := resourcelock.New(
+		resourcelock.ConfigMapsResourceLock,
+		config.Namespace,
+		config.Name,
+		client.CoreV1(),
+		resourcelock.ResourceLockConfig{
+			Identity:      id,
+			EventRecorder: recorder,
+		},
+	)
+	check(err)
+
+	e := &elector{
+		FieldLogger:          log.WithField("context", "leaderelection").WithField("identity", id),
+		leaderElectionConfig: config,
+		lock:                 rl,
+		elected:              elected,
+		deposed:              deposed,
+	}
+	g.Add(e.run)
+}
+
+// validate returns an error if the durations of c would let the
+// lease lapse before the leader gives up renewing it.
+func (c *leaderElectionConfig) validate() error {
+	if c.LeaseDuration <= c.RenewDeadline {
+		return fmt.Errorf("leader election lease duration %v must be greater than renew deadline %v", c.LeaseDuration, c.RenewDeadline)
+	}
+	if c.RenewDeadline <= time.Duration(leaderelection.JitterFactor*float64(c.RetryPeriod)) {
+		return fmt.Errorf("leader election renew deadline %v must be greater than retry period %v * %v", c.RenewDeadline, c.RetryPeriod, leaderelection.JitterFactor)
+	}
+	return nil
+}
+
+// elector campaigns for a resourcelock.Interface lease. It follows the
+// protocol of client-go's LeaderElector, whose Run, at the version of
+// client-go Contour uses, can neither be stopped nor run again once the
+// lease is lost.
+type elector struct {
+	logrus.FieldLogger
+	leaderElectionConfig
+
+	lock resourcelock.Interface
+
+	// elected and deposed are called when the lease is acquired and lost.
+	elected, deposed func()
+
+	// now, if set, returns the current time, otherwise time.Now is used.
+	now func() time.Time
+
+	// observed is the last lease record read or written, at observedTime.
+	observed     resourcelock.LeaderElectionRecord
+	observedTime time.Time
+}
+
+// run campaigns for the lease until stop is closed.
+func (e *elector) run(stop <-chan struct{}) error {
+	e.WithField("configmap", e.Namespace+"/"+e.Name).Info("started")
+	defer e.Info("stopped")
+
+	for e.acquire(stop) {
+		e.Info("elected leader")
+		e.elected()
+		if !e.renew(stop) {
+			// stopped while leader, the lease lapses on its own.
+			return nil
+		}
+		e.Info("lost leadership")
+		e.deposed()
+	}
+	return nil
+}
+
+// acquire tries to acquire the lease every RetryPeriod, with jitter. It
+// returns true once the lease is acquired, or false if stop is closed.
+func (e *elector) acquire(stop <-chan struct{}) bool {
+	for {
+		if e.tryAcquireOrRenew() {
+			e.lock.RecordEvent("became leader")
+			return true
+		}
+		select {
+		case <-stop:
+			return false
+		case <-time.After(wait.Jitter(e.RetryPeriod, leaderelection.JitterFactor)):
+		}
+	}
+}
+
+// renew renews the lease every RetryPeriod. It returns true once the
+// lease has not been renewed for RenewDeadline, or false if stop is
+// closed.
+func (e *elector) renew(stop <-chan struct{}) bool {
+	renewed := e.clock()
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-time.After(e.RetryPeriod):
+		}
+		now := e.clock()
+		if e.tryAcquireOrRenew() {
+			renewed = now
+			continue
+		}
+		if now.Sub(renewed) >= e.RenewDeadline {
+			e.lock.RecordEvent("stopped leading")
+			return true
+		}
+	}
+}
+
+// tryAcquireOrRenew tries to acquire the lease, or renew it if already
+// held, and returns true if it succeeds.
+func (e *elector) tryAcquireOrRenew() bool {
+	now := metav1.NewTime(e.clock())
+	record := resourcelock.LeaderElectionRecord{
+		HolderIdentity:       e.lock.Identity(),
+		LeaseDurationSeconds: int(e.LeaseDuration / time.Second),
+		RenewTime:            now,
+		AcquireTime:          now,
+	}
+
+	old, err := e.lock.Get()
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			e.WithError(err).Error("failed to get lease")
+			return false
+		}
+		if err := e.lock.Create(record); err != nil {
+			e.WithError(err).Error("failed to create lease")
+			return false
+		}
+		e.observe(record, now.Time)
+		return true
+	}
+
+	if !reflect.DeepEqual(e.observed, *old) {
+		e.observe(*old, now.Time)
+	}
+	if old.HolderIdentity != record.HolderIdentity && e.observedTime.Add(e.LeaseDuration).After(now.Time) {
+		// another replica holds a lease which has not lapsed.
+		return false
+	}
+
+	if old.HolderIdentity == record.HolderIdentity {
+		record.AcquireTime = old.AcquireTime
+		record.LeaderTransitions = old.LeaderTransitions
+	} else {
+		record.LeaderTransitions = old.LeaderTransitions + 1
+	}
+	if err := e.lock.Update(record); err != nil {
+		e.WithError(err).Error("failed to update lease")
+		return false
+	}
+	e.observe(record, now.Time)
+	return true
+}
+
+// observe records r as the lease last seen, at t.
+func (e *elector) observe(r resourcelock.LeaderElectionRecord, t time.Time) {
+	if r.HolderIdentity != e.observed.HolderIdentity {
+		e.WithField("leader", r.HolderIdentity).Info("new leader")
+	}
+	e.observed = r
+	e.observedTime = t
+}
+
+func (e *elector) clock() time.Time {
+	if e.now == nil {
+		return time.Now()
+	}
+	return e.now()
+}
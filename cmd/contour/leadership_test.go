@@ -0,0 +1,209 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestElectorTryAcquireOrRenew(t *testing.T) {
+	now := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	lock := &fakeLock{identity: "contour-a"}
+	e := testElector(lock)
+	e.now = func() time.Time { return now }
+
+	// no lease, it is created.
+	if !e.tryAcquireOrRenew() {
+		t.Fatal("expected to create the lease")
+	}
+	if got := lock.get().HolderIdentity; got != "contour-a" {
+		t.Fatalf("expected holder contour-a, got %q", got)
+	}
+
+	// held by us, it is renewed.
+	now = now.Add(time.Second)
+	if !e.tryAcquireOrRenew() {
+		t.Fatal("expected to renew the lease")
+	}
+	if got := lock.get(); !got.RenewTime.Time.Equal(now) || got.AcquireTime.Time.Equal(now) {
+		t.Fatalf("expected renew time %v, and the original acquire time, got %+v", now, got)
+	}
+
+	// held by another replica, it is not acquired until it lapses.
+	lock.set(resourcelock.LeaderElectionRecord{HolderIdentity: "contour-b", LeaderTransitions: 3})
+	if e.tryAcquireOrRenew() {
+		t.Fatal("expected not to acquire the lease of another replica")
+	}
+	now = now.Add(e.LeaseDuration - time.Millisecond)
+	if e.tryAcquireOrRenew() {
+		t.Fatal("expected not to acquire the lease of another replica before it lapses")
+	}
+	now = now.Add(time.Millisecond)
+	if !e.tryAcquireOrRenew() {
+		t.Fatal("expected to acquire the lapsed lease of another replica")
+	}
+	if got := lock.get(); got.HolderIdentity != "contour-a" || got.LeaderTransitions != 4 {
+		t.Fatalf("expected holder contour-a, with 4 transitions, got %+v", got)
+	}
+}
+
+func TestElectorRun(t *testing.T) {
+	lock := &fakeLock{identity: "contour-a"}
+	e := testElector(lock)
+	elected := make(chan struct{}, 1)
+	deposed := make(chan struct{}, 1)
+	e.elected = func() { elected <- struct{}{} }
+	e.deposed = func() { deposed <- struct{}{} }
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- e.run(stop) }()
+
+	wait := func(ch chan struct{}, what string) {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting to be %s", what)
+		}
+	}
+	wait(elected, "elected")
+
+	// the lease can no longer be renewed, leadership is lost but the
+	// elector keeps running, and is elected again once it can.
+	lock.fail(errors.New("unavailable"))
+	wait(deposed, "deposed")
+	lock.fail(nil)
+	wait(elected, "elected again")
+
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected run to return nil, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for run to return once stopped")
+	}
+}
+
+func TestLeaderElectionConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		leaderElectionConfig
+		valid bool
+	}{
+		"defaults": {
+			leaderElectionConfig: leaderElectionConfig{LeaseDuration: 15 * time.Second, RenewDeadline: 10 * time.Second, RetryPeriod: 2 * time.Second},
+			valid:                true,
+		},
+		"lease duration not greater than renew deadline": {
+			leaderElectionConfig: leaderElectionConfig{LeaseDuration: 10 * time.Second, RenewDeadline: 10 * time.Second, RetryPeriod: 2 * time.Second},
+		},
+		"renew deadline not greater than retry period": {
+			leaderElectionConfig: leaderElectionConfig{LeaseDuration: 15 * time.Second, RenewDeadline: 2 * time.Second, RetryPeriod: 2 * time.Second},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.validate()
+			if tc.valid != (err == nil) {
+				t.Fatalf("expected valid: %v, got: %v", tc.valid, err)
+			}
+		})
+	}
+}
+
+func testElector(lock resourcelock.Interface) *elector {
+	log := logrus.New()
+	log.Out = ioutil.Discard
+	return &elector{
+		FieldLogger: log,
+		leaderElectionConfig: leaderElectionConfig{
+			Namespace:     "heptio-contour",
+			Name:          "contour",
+			LeaseDuration: 150 * time.Millisecond,
+			RenewDeadline: 100 * time.Millisecond,
+			RetryPeriod:   20 * time.Millisecond,
+		},
+		lock: lock,
+	}
+}
+
+// fakeLock is an in memory resourcelock.Interface.
+type fakeLock struct {
+	identity string
+
+	mu     sync.Mutex
+	record *resourcelock.LeaderElectionRecord
+	err    error
+}
+
+func (l *fakeLock) Get() (*resourcelock.LeaderElectionRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return nil, l.err
+	}
+	if l.record == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "contour")
+	}
+	r := *l.record
+	return &r, nil
+}
+
+func (l *fakeLock) Create(r resourcelock.LeaderElectionRecord) error { return l.Update(r) }
+
+func (l *fakeLock) Update(r resourcelock.LeaderElectionRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	l.record = &r
+	return nil
+}
+
+func (l *fakeLock) RecordEvent(string) {}
+func (l *fakeLock) Identity() string   { return l.identity }
+func (l *fakeLock) Describe() string   { return "heptio-contour/contour" }
+
+func (l *fakeLock) get() resourcelock.LeaderElectionRecord {
+	r, err := l.Get()
+	if err != nil {
+		panic(err)
+	}
+	return *r
+}
+
+func (l *fakeLock) set(r resourcelock.LeaderElectionRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.record = &r
+}
+
+// fail makes every subsequent call to Get, Create, and Update return err.
+func (l *fakeLock) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.err = err
+}
@@ -20,18 +20,16 @@ import (
 	"time"
 
 	"github.com/gogo/protobuf/types"
-	"k8s.io/api/extensions/v1beta1"
 )
 
 const (
 	// set docs/annotations.md for details of how these annotations
 	// are applied by Contour.
 
-	annotationRequestTimeout  = "contour.heptio.com/request-timeout"
-	annotationRetryOn         = "contour.heptio.com/retry-on"
-	annotationNumRetries      = "contour.heptio.com/num-retries"
-	annotationPerTryTimeout   = "contour.heptio.com/per-try-timeout"
-	annotationWebsocketRoutes = "contour.heptio.com/websocket-routes"
+	annotationRequestTimeout = "contour.heptio.com/request-timeout"
+	annotationRetryOn        = "contour.heptio.com/retry-on"
+	annotationNumRetries     = "contour.heptio.com/num-retries"
+	annotationPerTryTimeout  = "contour.heptio.com/per-try-timeout"
 
 	// By default envoy applies a 15 second timeout to all backend requests.
 	// The explicit value 0 turns off the timeout, implying "never time out"
@@ -92,28 +90,3 @@ func parseUpstreamProtocols(annotations map[string]string, annotation string, pr
 	}
 	return up
 }
-
-// httpAllowed returns true unless the kubernetes.io/ingress.allow-http annotation is
-// present and set to false.
-func httpAllowed(i *v1beta1.Ingress) bool {
-	return !(i.Annotations["kubernetes.io/ingress.allow-http"] == "false")
-}
-
-// tlsRequired returns true if the ingress.kubernetes.io/force-ssl-redirect annotation is
-// present and set to true.
-func tlsRequired(i *v1beta1.Ingress) bool {
-	return i.Annotations["ingress.kubernetes.io/force-ssl-redirect"] == "true"
-}
-
-// websocketRoutes returns a map of websocket routes. If the value is not present, or
-// malformed, then an empty map is returned.
-func websocketRoutes(i *v1beta1.Ingress) map[string]*types.BoolValue {
-	routes := make(map[string]*types.BoolValue)
-	for _, v := range strings.Split(i.Annotations[annotationWebsocketRoutes], ",") {
-		route := strings.TrimSpace(v)
-		if route != "" {
-			routes[route] = &types.BoolValue{Value: true}
-		}
-	}
-	return routes
-}
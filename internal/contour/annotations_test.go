@@ -21,9 +21,6 @@ import (
 	"time"
 
 	"github.com/gogo/protobuf/types"
-	"k8s.io/api/extensions/v1beta1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func TestParseAnnotationTimeout(t *testing.T) {
@@ -156,121 +153,3 @@ func TestParseUpstreamProtocols(t *testing.T) {
 		})
 	}
 }
-
-func TestWebsocketRoutes(t *testing.T) {
-	tests := map[string]struct {
-		a    *v1beta1.Ingress
-		want map[string]*types.BoolValue
-	}{
-		"empty": {
-			a: &v1beta1.Ingress{
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: map[string]string{annotationWebsocketRoutes: ""},
-				},
-			},
-			want: map[string]*types.BoolValue{},
-		},
-		"empty with spaces": {
-			a: &v1beta1.Ingress{
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: map[string]string{annotationWebsocketRoutes: ", ,"},
-				},
-			},
-			want: map[string]*types.BoolValue{},
-		},
-		"single value": {
-			a: &v1beta1.Ingress{
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: map[string]string{annotationWebsocketRoutes: "/ws1"},
-				},
-			},
-			want: map[string]*types.BoolValue{
-				"/ws1": &types.BoolValue{Value: true},
-			},
-		},
-		"multiple values": {
-			a: &v1beta1.Ingress{
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: map[string]string{annotationWebsocketRoutes: "/ws1,/ws2"},
-				},
-			},
-			want: map[string]*types.BoolValue{
-				"/ws1": &types.BoolValue{Value: true},
-				"/ws2": &types.BoolValue{Value: true},
-			},
-		},
-		"multiple values with spaces and invalid entries": {
-			a: &v1beta1.Ingress{
-				ObjectMeta: metav1.ObjectMeta{
-					Annotations: map[string]string{annotationWebsocketRoutes: " /ws1, , /ws2 "},
-				},
-			},
-			want: map[string]*types.BoolValue{
-				"/ws1": &types.BoolValue{Value: true},
-				"/ws2": &types.BoolValue{Value: true},
-			},
-		},
-	}
-
-	for name, tc := range tests {
-		t.Run(name, func(t *testing.T) {
-			got := websocketRoutes(tc.a)
-			if !reflect.DeepEqual(tc.want, got) {
-				t.Fatalf("websocketRoutes(%q): want: %v, got: %v", tc.a, tc.want, got)
-			}
-		})
-	}
-}
-
-func TestHttpAllowed(t *testing.T) {
-	tests := map[string]struct {
-		i     *v1beta1.Ingress
-		valid bool
-	}{
-		"basic ingress": {
-			i: &v1beta1.Ingress{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "simple",
-					Namespace: "default",
-				},
-				Spec: v1beta1.IngressSpec{
-					TLS: []v1beta1.IngressTLS{{
-						Hosts:      []string{"whatever.example.com"},
-						SecretName: "secret",
-					}},
-					Backend: backend("backend", intstr.FromInt(80)),
-				},
-			},
-			valid: true,
-		},
-		"kubernetes.io/ingress.allow-http: \"false\"": {
-			i: &v1beta1.Ingress{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "simple",
-					Namespace: "default",
-					Annotations: map[string]string{
-						"kubernetes.io/ingress.allow-http": "false",
-					},
-				},
-				Spec: v1beta1.IngressSpec{
-					TLS: []v1beta1.IngressTLS{{
-						Hosts:      []string{"whatever.example.com"},
-						SecretName: "secret",
-					}},
-					Backend: backend("backend", intstr.FromInt(80)),
-				},
-			},
-			valid: false,
-		},
-	}
-
-	for name, tc := range tests {
-		t.Run(name, func(t *testing.T) {
-			got := httpAllowed(tc.i)
-			want := tc.valid
-			if got != want {
-				t.Fatalf("got: %v, want: %v", got, want)
-			}
-		})
-	}
-}
@@ -45,6 +45,23 @@ func (c *cache) remove(name string) {
 	c.mu.Unlock()
 }
 
+// replace replaces the contents of the cache with values. replace
+// returns true if the contents of the cache changed.
+func (c *cache) replace(values map[string]proto.Message) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changed := len(values) != len(c.entries)
+	for n, v := range values {
+		if changed {
+			break
+		}
+		e, ok := c.entries[n]
+		changed = !ok || !proto.Equal(e, v)
+	}
+	c.entries = values
+	return changed
+}
+
 // Values returns a slice of the value stored in the cache.
 func (c *cache) Values(filter func(string) bool) []proto.Message {
 	c.mu.Lock()
@@ -71,6 +88,16 @@ func (cc *clusterCache) Add(clusters ...*v2.Cluster) {
 	}
 }
 
+// Replace replaces the contents of the cache with clusters.
+// Replace returns true if the contents of the cache changed.
+func (cc *clusterCache) Replace(clusters ...*v2.Cluster) bool {
+	values := make(map[string]proto.Message, len(clusters))
+	for _, c := range clusters {
+		values[c.Name] = c
+	}
+	return cc.replace(values)
+}
+
 // Remove removes the named entry from the cache. If the entry
 // is not present in the cache, the operation is a no-op.
 func (cc *clusterCache) Remove(names ...string) {
@@ -113,6 +140,16 @@ func (lc *listenerCache) Add(listeners ...*v2.Listener) {
 	}
 }
 
+// Replace replaces the contents of the cache with listeners.
+// Replace returns true if the contents of the cache changed.
+func (lc *listenerCache) Replace(listeners ...*v2.Listener) bool {
+	values := make(map[string]proto.Message, len(listeners))
+	for _, l := range listeners {
+		values[l.Name] = l
+	}
+	return lc.replace(values)
+}
+
 // Remove removes the named entry from the cache. If the entry
 // is not present in the cache, the operation is a no-op.
 func (lc *listenerCache) Remove(names ...string) {
@@ -129,24 +166,43 @@ type virtualHostCache struct {
 // Add adds an entry to the cache. If a VirtualHost with the same
 // name exists, it is replaced.
 func (vc *virtualHostCache) Add(virtualhosts ...*route.VirtualHost) {
-next:
 	for _, v := range virtualhosts {
-		if v.Name == "" {
-			logrus.WithField("virtualhost", v).Println("skipping VirtualHost with empty name")
-			continue
+		if validVirtualHost(v) {
+			vc.insert(v.Name, v)
 		}
-		if len(v.Domains) == 0 {
-			logrus.WithField("virtualhost", v).Println("skipping VirtualHost with blank domain list")
-			continue
+	}
+}
+
+// Replace replaces the contents of the cache with virtualhosts.
+// Replace returns true if the contents of the cache changed.
+func (vc *virtualHostCache) Replace(virtualhosts ...*route.VirtualHost) bool {
+	values := make(map[string]proto.Message, len(virtualhosts))
+	for _, v := range virtualhosts {
+		if validVirtualHost(v) {
+			values[v.Name] = v
 		}
-		for _, d := range v.Domains {
-			if d == "" {
-				logrus.WithField("virtualhost", v).Println("skipping VirtualHost with blank entry in domain list")
-				continue next
-			}
+	}
+	return vc.replace(values)
+}
+
+// validVirtualHost returns true if v has a name and a
+// list of domains without blank entries.
+func validVirtualHost(v *route.VirtualHost) bool {
+	if v.Name == "" {
+		logrus.WithField("virtualhost", v).Println("skipping VirtualHost with empty name")
+		return false
+	}
+	if len(v.Domains) == 0 {
+		logrus.WithField("virtualhost", v).Println("skipping VirtualHost with blank domain list")
+		return false
+	}
+	for _, d := range v.Domains {
+		if d == "" {
+			logrus.WithField("virtualhost", v).Println("skipping VirtualHost with blank entry in domain list")
+			return false
 		}
-		vc.insert(v.Name, v)
 	}
+	return true
 }
 
 // Remove removes the named entry from the cache. If the entry
@@ -119,6 +119,82 @@ func TestCacheRemove(t *testing.T) {
 	}
 }
 
+func TestCacheReplace(t *testing.T) {
+	val := v2.Cluster{Name: "alpha"}
+	val2 := v2.Cluster{Name: "alpha", ConnectTimeout: 250}
+
+	tests := map[string]*struct {
+		cache
+		values  map[string]proto.Message
+		changed bool
+	}{
+		"empty, replace with nothing": {
+			values:  map[string]proto.Message{},
+			changed: false,
+		},
+		"empty, replace with one key": {
+			values: map[string]proto.Message{
+				"alpha": &val,
+			},
+			changed: true,
+		},
+		"one key, replace with equal value": {
+			cache: cache{
+				entries: map[string]proto.Message{
+					"alpha": &val,
+				},
+			},
+			values: map[string]proto.Message{
+				"alpha": &v2.Cluster{Name: "alpha"},
+			},
+			changed: false,
+		},
+		"one key, replace with different value": {
+			cache: cache{
+				entries: map[string]proto.Message{
+					"alpha": &val,
+				},
+			},
+			values: map[string]proto.Message{
+				"alpha": &val2,
+			},
+			changed: true,
+		},
+		"one key, replace with different key": {
+			cache: cache{
+				entries: map[string]proto.Message{
+					"alpha": &val,
+				},
+			},
+			values: map[string]proto.Message{
+				"beta": &val,
+			},
+			changed: true,
+		},
+		"one key, replace with nothing": {
+			cache: cache{
+				entries: map[string]proto.Message{
+					"alpha": &val,
+				},
+			},
+			values:  map[string]proto.Message{},
+			changed: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			changed := tc.cache.replace(tc.values)
+			if changed != tc.changed {
+				t.Fatalf("changed: expected: %v, got: %v", tc.changed, changed)
+			}
+			if !reflect.DeepEqual(tc.cache.entries, tc.values) {
+				t.Fatalf("expected: %#v, got %#v", tc.values, tc.cache.entries)
+			}
+		})
+	}
+}
+
 func TestCacheValues(t *testing.T) {
 	var (
 		c  cache
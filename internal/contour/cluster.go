@@ -14,13 +14,13 @@
 package contour
 
 import (
-	"strconv"
 	"time"
 
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
 	v2cluster "github.com/envoyproxy/go-control-plane/envoy/api/v2/cluster"
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"github.com/heptio/contour/internal/dag"
 	"k8s.io/api/core/v1"
 )
 
@@ -34,16 +34,17 @@ const (
 
 // ClusterCache manage the contents of the gRPC SDS cache.
 type ClusterCache struct {
+	// UseADS configures clusters to retrieve their endpoints over
+	// the aggregated discovery service.
+	// If not set, defaults to false.
+	UseADS bool
+
 	clusterCache
 	Cond
 }
 
-// recomputeService recomputes SDS cache entries, adding, updating, or removing
-// entries as required.
-// If oldsvc is nil, entries in newsvc are unconditionally added to the SDS cache.
-// If oldsvc differs to newsvc, then the entries present only oldsvc will be removed from
-// the SDS cache, present in newsvc will be added. If newsvc is nil, entries in oldsvc
-// will be unconditionally deleted.
+// recompute recomputes the CDS cache from the Services in d and
+// notifies watchers of any change.
 //
 // Each CDS entry has a name, which is hashed according to the envoy 60 char limit, and
 // a sevicename which is freeform. The servicename is how EDS and CDS locate each other.
@@ -53,84 +54,45 @@ type ClusterCache struct {
 // names come in the form NAMESPACE / NAME / SERVICEPORT NAME. However SERVICEPORT NAME
 // may be blank, and so both the SERVICEPORT NAME component and the preceeding slash may
 // be elided in the case that there is a single, unnamed, service port in the spec.
-func (cc *ClusterCache) recomputeService(oldsvc, newsvc *v1.Service) {
-	if oldsvc == newsvc {
-		// skip if oldsvc & newsvc == nil, or are the same object.
-		return
-	}
-
-	defer cc.Notify()
-
-	if oldsvc == nil {
-		// if oldsvc is nil, replace it with a blank spec so entries
-		// are unconditionally added.
-		oldsvc = &v1.Service{
-			ObjectMeta: newsvc.ObjectMeta,
+func (cc *ClusterCache) recompute(d *dag.DAG) {
+	var clusters []*v2.Cluster
+	d.Visit(func(v dag.Vertex) {
+		s, ok := v.(*dag.Service)
+		if !ok || s.Object == nil {
+			return
 		}
-	}
-
-	if newsvc == nil {
-		// if newsvc is nil, replace it with a blank spec so entries
-		// are unconditaionlly deleted.
-		newsvc = &v1.Service{
-			ObjectMeta: oldsvc.ObjectMeta,
-		}
-	}
-
-	// parse upstream protocol annotations
-	up := parseUpstreamProtocols(newsvc.Annotations, annotationUpstreamProtocol, "h2", "h2c")
-
-	// iterate over all ports in newsvc adding or updating their records and
-	// recording that face in named and unnamed.
-	named := make(map[string]v1.ServicePort)
-	unnamed := make(map[int32]v1.ServicePort)
-	for _, p := range newsvc.Spec.Ports {
-		switch p.Protocol {
-		case "TCP":
-			// eds needs a stable name to find this sds entry.
-			// ideally we can generate this information from that recorded by the
-			// endpoint controller in the endpoint record.
-			// p.Name will be blank on the condition that there is a single serviceport
-			// entry in this service spec.
-			config := edsconfig("contour", servicename(newsvc.ObjectMeta, p.Name))
-			if p.Name != "" {
-				// service port is named, so we must generate both a cluster for the port name
-				// and a cluster for the port number.
-				c := edscluster(newsvc, p.Name, up[p.Name], config)
-				cc.Add(c)
-				// it is safe to use p.Name as the key because the API server enforces
-				// the invariant that Name will only be blank if there is a single port
-				// in the service spec. This there will only be one entry in the map,
-				// { "": p }
-				named[p.Name] = p
+		// parse upstream protocol annotations
+		up := parseUpstreamProtocols(s.Object.Annotations, annotationUpstreamProtocol, "h2", "h2c", "tls")
+		protocol := up[s.Port]
+		if uv := s.UpstreamValidation; uv != nil {
+			if uv.CACertificate == nil {
+				// the CA certificate is missing, omit the cluster
+				// rather than forward to an unverified endpoint.
+				return
+			}
+			if protocol != "h2" {
+				// verification requires TLS.
+				protocol = "tls"
 			}
-			portString := strconv.Itoa(int(p.Port))
-			c := edscluster(newsvc, portString, up[portString], config)
-			cc.Add(c)
-			unnamed[p.Port] = p
-		default:
-			// ignore UDP and other port types.
 		}
-	}
 
-	// iterate over all the ports in oldsvc, if they are not found in named or unnamed then remove their
-	// entires from the cache.
-	for _, p := range oldsvc.Spec.Ports {
-		switch p.Protocol {
-		case "TCP":
-			if _, found := named[p.Name]; !found {
-				cc.Remove(hashname(60, oldsvc.ObjectMeta.Namespace, oldsvc.ObjectMeta.Name, p.Name))
-			}
-			if _, found := unnamed[p.Port]; !found {
-				cc.Remove(hashname(60, oldsvc.ObjectMeta.Namespace, oldsvc.ObjectMeta.Name, strconv.Itoa(int(p.Port))))
-			}
-		default:
-			// ignore UDP and other port types.
+		// eds needs a stable name to find this sds entry.
+		// ideally we can generate this information from that recorded by the
+		// endpoint controller in the endpoint record.
+		// s.ServicePort.Name will be blank on the condition that there is a single
+		// serviceport entry in this service spec.
+		config := edsconfig("contour", servicename(s.Object.ObjectMeta, s.ServicePort.Name))
+		if cc.UseADS {
+			config.EdsConfig = adsconfigsource()
 		}
+		clusters = append(clusters, edscluster(s.Object, s.Port, protocol, s.UpstreamValidation, config))
+	})
+	if cc.Replace(clusters...) {
+		cc.Notify()
 	}
 }
 
-func edscluster(svc *v1.Service, portString, upstreamProtocol string, config *v2.Cluster_EdsClusterConfig) *v2.Cluster {
+func edscluster(svc *v1.Service, portString, upstreamProtocol string, validation *dag.UpstreamValidation, config *v2.Cluster_EdsClusterConfig) *v2.Cluster {
 	cluster := &v2.Cluster{
 		Name:             hashname(60, svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, portString),
 		Type:             v2.Cluster_EDS,
@@ -160,11 +122,38 @@ func edscluster(svc *v1.Service, portString, upstreamProtocol string, config *v2
 		}
 	case "h2c":
 		cluster.Http2ProtocolOptions = &core.Http2ProtocolOptions{}
+	case "tls":
+		cluster.TlsContext = &auth.UpstreamTlsContext{}
+	}
+
+	if cluster.TlsContext != nil && validation != nil && validation.CACertificate != nil {
+		if cluster.TlsContext.CommonTlsContext == nil {
+			cluster.TlsContext.CommonTlsContext = &auth.CommonTlsContext{}
+		}
+		cluster.TlsContext.CommonTlsContext.ValidationContext = upstreamValidationContext(validation)
+		cluster.TlsContext.Sni = validation.SubjectName
 	}
 
 	return cluster
 }
 
+// upstreamValidationContext returns a CertificateValidationContext which
+// verifies an endpoint's certificate against the CA certificate, and
+// subject name, of validation.
+func upstreamValidationContext(validation *dag.UpstreamValidation) *auth.CertificateValidationContext {
+	vc := &auth.CertificateValidationContext{
+		TrustedCa: &core.DataSource{
+			Specifier: &core.DataSource_InlineBytes{
+				InlineBytes: validation.CACertificate.Object.Data[dag.CACertificateKey],
+			},
+		},
+	}
+	if validation.SubjectName != "" {
+		vc.VerifySubjectAltName = []string{validation.SubjectName}
+	}
+	return vc
+}
+
 func edsconfig(source, name string) *v2.Cluster_EdsClusterConfig {
 	return &v2.Cluster_EdsClusterConfig{
 		EdsConfig:   apiconfigsource(source), // hard coded by initconfig
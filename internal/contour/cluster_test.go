@@ -28,11 +28,12 @@ import (
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	"github.com/gogo/protobuf/proto"
+	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
 )
 
 // TODO(dfc) clean up these tests with helpers for the want: fixtures.
 
-func TestClusterCacheRecomputeService(t *testing.T) {
+func TestClusterCacheRecompute(t *testing.T) {
 	tests := map[string]struct {
 		oldObj *v1.Service
 		newObj *v1.Service
@@ -361,7 +362,10 @@ func TestClusterCacheRecomputeService(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			var cc ClusterCache
-			cc.recomputeService(tc.oldObj, tc.newObj)
+			if tc.oldObj != nil {
+				cc.recompute(buildDAG(tc.oldObj))
+			}
+			cc.recompute(buildDAG(tc.newObj))
 			got := contents(&cc)
 			sort.Stable(clusterByName(got))
 			if !reflect.DeepEqual(tc.want, got) {
@@ -371,6 +375,183 @@ func TestClusterCacheRecomputeService(t *testing.T) {
 	}
 }
 
+func TestClusterCacheRecomputeADS(t *testing.T) {
+	cc := ClusterCache{UseADS: true}
+	cc.recompute(buildDAG(service("default", "kuard",
+		v1.ServicePort{
+			Protocol:   "TCP",
+			Port:       443,
+			TargetPort: intstr.FromInt(8443),
+		},
+	)))
+	want := []proto.Message{
+		&v2.Cluster{
+			Name: "default/kuard/443",
+			Type: v2.Cluster_EDS,
+			EdsClusterConfig: &v2.Cluster_EdsClusterConfig{
+				EdsConfig:   adsconfigsource(),
+				ServiceName: "default/kuard",
+			},
+			ConnectTimeout: 250 * time.Millisecond,
+			LbPolicy:       v2.Cluster_ROUND_ROBIN,
+		},
+	}
+	got := contents(&cc)
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("expected:\n%v\ngot:\n%v\n", want, got)
+	}
+}
+
+func TestClusterCacheUpstreamValidation(t *testing.T) {
+	ca := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ca",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"ca.crt": []byte("ca"),
+		},
+	}
+	tlscluster := func(serviceName string) *v2.Cluster {
+		return &v2.Cluster{
+			Name: "default/kuard/443",
+			Type: v2.Cluster_EDS,
+			EdsClusterConfig: &v2.Cluster_EdsClusterConfig{
+				EdsConfig:   apiconfigsource("contour"), // hard coded by initconfig
+				ServiceName: serviceName,
+			},
+			ConnectTimeout: 250 * time.Millisecond,
+			LbPolicy:       v2.Cluster_ROUND_ROBIN,
+			TlsContext: &auth.UpstreamTlsContext{
+				CommonTlsContext: &auth.CommonTlsContext{
+					ValidationContext: &auth.CertificateValidationContext{
+						TrustedCa: &core.DataSource{
+							Specifier: &core.DataSource_InlineBytes{
+								InlineBytes: []byte("ca"),
+							},
+						},
+						VerifySubjectAltName: []string{"kuard.default"},
+					},
+				},
+				Sni: "kuard.default",
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		objs []interface{}
+		want []proto.Message
+	}{
+		"tls upstream without validation": {
+			objs: []interface{}{
+				serviceWithAnnotations("default", "kuard",
+					map[string]string{
+						fmt.Sprintf("%s.%s", annotationUpstreamProtocol, "tls"): "443",
+					},
+					v1.ServicePort{
+						Protocol: "TCP",
+						Port:     443,
+					},
+				),
+			},
+			want: []proto.Message{
+				&v2.Cluster{
+					Name: "default/kuard/443",
+					Type: v2.Cluster_EDS,
+					EdsClusterConfig: &v2.Cluster_EdsClusterConfig{
+						EdsConfig:   apiconfigsource("contour"), // hard coded by initconfig
+						ServiceName: "default/kuard",
+					},
+					ConnectTimeout: 250 * time.Millisecond,
+					LbPolicy:       v2.Cluster_ROUND_ROBIN,
+					TlsContext:     &auth.UpstreamTlsContext{},
+				},
+			},
+		},
+		"annotated ca secret": {
+			objs: []interface{}{
+				ca,
+				serviceWithAnnotations("default", "kuard",
+					map[string]string{
+						fmt.Sprintf("%s.%s", annotationUpstreamProtocol, "tls"): "443",
+						"contour.heptio.com/upstream-ca-secret":                 "ca",
+						"contour.heptio.com/upstream-subject-name":              "kuard.default",
+					},
+					v1.ServicePort{
+						Protocol: "TCP",
+						Port:     443,
+					},
+				),
+			},
+			want: []proto.Message{
+				tlscluster("default/kuard"),
+			},
+		},
+		"annotated ca secret missing": {
+			objs: []interface{}{
+				serviceWithAnnotations("default", "kuard",
+					map[string]string{
+						fmt.Sprintf("%s.%s", annotationUpstreamProtocol, "tls"): "443",
+						"contour.heptio.com/upstream-ca-secret":                 "ca",
+					},
+					v1.ServicePort{
+						Protocol: "TCP",
+						Port:     443,
+					},
+				),
+			},
+			want: []proto.Message{},
+		},
+		"ingressroute validation": {
+			objs: []interface{}{
+				ca,
+				service("default", "kuard",
+					v1.ServicePort{
+						Protocol: "TCP",
+						Port:     443,
+					},
+				),
+				&ingressroutev1.IngressRoute{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "kuard",
+						Namespace: "default",
+					},
+					Spec: ingressroutev1.IngressRouteSpec{
+						VirtualHost: ingressroutev1.VirtualHost{
+							Fqdn: "kuard.example.com",
+						},
+						Routes: []ingressroutev1.Route{{
+							Match: "/",
+							Services: []ingressroutev1.Service{{
+								Name: "kuard",
+								Port: 443,
+								UpstreamValidation: &ingressroutev1.UpstreamValidation{
+									CACertificate: "ca",
+									SubjectName:   "kuard.default",
+								},
+							}},
+						}},
+					},
+				},
+			},
+			want: []proto.Message{
+				tlscluster("default/kuard"),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var cc ClusterCache
+			cc.recompute(buildDAG(tc.objs...))
+			got := contents(&cc)
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("expected:\n%v\ngot:\n%v\n", tc.want, got)
+			}
+		})
+	}
+}
+
 func TestServiceName(t *testing.T) {
 	tests := map[string]struct {
 		meta metav1.ObjectMeta
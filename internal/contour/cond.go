@@ -57,3 +57,10 @@ func (c *Cond) Notify() {
 	}
 	c.waiters = c.waiters[:0]
 }
+
+// Notifications returns the number of times Notify has been called.
+func (c *Cond) Notifications() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
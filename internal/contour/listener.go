@@ -19,9 +19,8 @@ import (
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
 	"github.com/gogo/protobuf/types"
-	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
+	"github.com/heptio/contour/internal/dag"
 	"k8s.io/api/core/v1"
-	"k8s.io/api/extensions/v1beta1"
 )
 
 const (
@@ -71,109 +70,50 @@ type ListenerCache struct {
 	// If not set, defaults to false.
 	UseProxyProto bool
 
+	// UseADS configures listeners to retrieve their routes over
+	// the aggregated discovery service.
+	// If not set, defaults to false.
+	UseADS bool
+
 	listenerCache
 	Cond
 }
 
-// recomputeListeners recomputes the ingress_http and ingress_https listeners
-// and notifies the watchers any change.
-func (lc *ListenerCache) recomputeListeners(ingresses map[metadata]*v1beta1.Ingress, secrets map[metadata]*v1.Secret) {
-	add, remove := lc.recomputeListener0(ingresses)                   // recompute ingress_http
-	ssladd, sslremove := lc.recomputeTLSListener0(ingresses, secrets) // recompute ingress_https
-
-	add = append(add, ssladd...)
-	remove = append(remove, sslremove...)
-	lc.Add(add...)
-	lc.Remove(remove...)
-
-	if len(add) > 0 || len(remove) > 0 {
-		lc.Notify()
+// recompute recomputes the ingress_http and ingress_https listeners
+// from d and notifies watchers of any change.
+func (lc *ListenerCache) recompute(d *dag.DAG) {
+	var listeners []*v2.Listener
+	if l := lc.httpListener(d); l != nil {
+		listeners = append(listeners, l)
 	}
-}
-
-// recomputeListenersIngressRoute recomputes the ingress_http and ingress_https listeners
-// and notifies the watchers any change.
-func (lc *ListenerCache) recomputeListenersIngressRoute(routes map[metadata]*ingressroutev1.IngressRoute, secrets map[metadata]*v1.Secret) {
-	add, remove := lc.recomputeListenerIngressRoute0(routes) // recompute ingress_http
-	// ssladd, sslremove := lc.recomputeTLSListener0(ingresses, secrets) // recompute ingress_https
-
-	// add = append(add, ssladd...)
-	// remove = append(remove, sslremove...)
-	lc.Add(add...)
-	lc.Remove(remove...)
-
-	if len(add) > 0 || len(remove) > 0 {
-		lc.Notify()
+	if l := lc.httpsListener(d); l != nil {
+		listeners = append(listeners, l)
 	}
-}
-
-// recomputeTLSListener recomputes the ingress_https listener and notifies the watchers
-// of any change.
-func (lc *ListenerCache) recomputeTLSListener(ingresses map[metadata]*v1beta1.Ingress, secrets map[metadata]*v1.Secret) {
-	ssladd, sslremove := lc.recomputeTLSListener0(ingresses, secrets) // recompute ingress_https
-	lc.Add(ssladd...)
-	lc.Remove(sslremove...)
-	if len(ssladd) > 0 || len(sslremove) > 0 {
+	if lc.Replace(listeners...) {
 		lc.Notify()
 	}
 }
 
-// recomputeListener recomputes the non SSL listener for port 8080 using the list of ingresses provided.
-// recomputeListener returns a slice of listeners to be added to the cache, and a slice of names of listeners
-// to be removed.
-func (lc *ListenerCache) recomputeListener0(ingresses map[metadata]*v1beta1.Ingress) ([]*v2.Listener, []string) {
-	l := &v2.Listener{
-		Name:    ENVOY_HTTP_LISTENER,
-		Address: socketaddress(lc.httpAddress(), lc.httpPort()),
-	}
-
-	var valid int
-	for _, i := range ingresses {
-		if httpAllowed(i) {
-			valid++
+// httpListener returns the non SSL listener for port 8080, or nil if
+// no virtual host in d has any routes.
+func (lc *ListenerCache) httpListener(d *dag.DAG) *v2.Listener {
+	valid := false
+	d.Visit(func(v dag.Vertex) {
+		if _, ok := v.(*dag.VirtualHost); ok {
+			valid = true
 		}
-	}
-	if valid > 0 {
-		l.FilterChains = []listener.FilterChain{
-			filterchain(lc.UseProxyProto, httpfilter(ENVOY_HTTP_LISTENER, lc.httpAccessLog())),
-		}
-	}
+	})
 	// TODO(dfc) some annotations may require the Ingress to no appear on
 	// port 80, therefore may result in an empty effective set of ingresses.
-	switch len(l.FilterChains) {
-	case 0:
-		// no ingresses registered, remove this listener.
-		return nil, []string{l.Name}
-	default:
-		// at least one ingress registered, refresh listener
-		return []*v2.Listener{l}, nil
+	if !valid {
+		return nil
 	}
-}
-
-// recomputeListener recomputes the non SSL listener for port 8080 using the list of routes provided.
-// recomputeListener returns a slice of listeners to be added to the cache, and a slice of names of listeners
-// to be removed.
-func (lc *ListenerCache) recomputeListenerIngressRoute0(routes map[metadata]*ingressroutev1.IngressRoute) ([]*v2.Listener, []string) {
-	l := &v2.Listener{
+	return &v2.Listener{
 		Name:    ENVOY_HTTP_LISTENER,
 		Address: socketaddress(lc.httpAddress(), lc.httpPort()),
-	}
-
-	if len(routes) > 0 {
-		l.FilterChains = []listener.FilterChain{
-			filterchain(lc.UseProxyProto, httpfilter(ENVOY_HTTP_LISTENER, lc.httpsAccessLog())),
-		}
-	}
-
-	// TODO(dfc) some annotations may require the Ingress to no appear on
-	// port 80, therefore may result in an empty effective set of ingresses.
-	switch len(l.FilterChains) {
-	case 0:
-		// no ingresses registered, remove this listener.
-		return nil, []string{l.Name}
-	default:
-		// at least one ingress registered, refresh listener
-		return []*v2.Listener{l}, nil
+		FilterChains: []listener.FilterChain{
+			filterchain(lc.UseProxyProto, httpfilter(ENVOY_HTTP_LISTENER, lc.httpAccessLog(), lc.UseADS)),
+		},
 	}
 }
 
@@ -204,69 +144,88 @@ func (lc *ListenerCache) httpAccessLog() string {
 	return DEFAULT_HTTP_ACCESS_LOG
 }
 
-// recomputeTLSListener0 recomputes the SSL listener for port 8443
-// using the list of ingresses and secrets provided.
-// recomputeListener returns a slice of listeners to be added to the cache,
-// and a slice of names of listeners to be removed. If the list of
-// TLS enabled listeners is zero, the listener is removed.
-func (lc *ListenerCache) recomputeTLSListener0(ingresses map[metadata]*v1beta1.Ingress, secrets map[metadata]*v1.Secret) ([]*v2.Listener, []string) {
+// httpsListener returns the SSL listener for port 8443, or nil if no
+// secure virtual host in d has a valid secret. Each secure virtual host
+// is served by its own filter chain, matching its host and aliases.
+// Each SNI name is served by at most one filter chain. The filter chains
+// of secure virtual hosts which verify their clients route only to their
+// own virtual host.
+func (lc *ListenerCache) httpsListener(d *dag.DAG) *v2.Listener {
 	l := &v2.Listener{
 		Name:    ENVOY_HTTPS_LISTENER,
 		Address: socketaddress(lc.httpsAddress(), lc.httpsPort()),
 	}
 
 	filters := []listener.Filter{
-		httpfilter(ENVOY_HTTPS_LISTENER, lc.httpsAccessLog()),
+		httpfilter(ENVOY_HTTPS_LISTENER, lc.httpsAccessLog(), lc.UseADS),
 	}
 
-	for _, i := range ingresses {
-		if !validTLSIngress(i) {
-			continue
+	// sni records the names already claimed by a filter chain,
+	// Envoy rejects listeners with overlapping filter chain matches.
+	sni := make(map[string]bool)
+
+	d.Visit(func(v dag.Vertex) {
+		vh, ok := v.(*dag.SecureVirtualHost)
+		if !ok || vh.Secret == nil {
+			return
 		}
-		for _, tls := range i.Spec.TLS {
-			secret, ok := secrets[metadata{name: tls.SecretName, namespace: i.Namespace}]
-			if !ok {
-				// no secret for this ingress yet, skip it
-				continue
-			}
-			_, cert := secret.Data[v1.TLSCertKey]
-			_, key := secret.Data[v1.TLSPrivateKeyKey]
-			if !cert || !key {
-				// missing cert or private key, skip it
-				continue
-			}
-			var tlsMinProtoVer auth.TlsParameters_TlsProtocol
-			switch i.ObjectMeta.Annotations["contour.heptio.com/tls-minimum-protocol-version"] {
-			case "1.3":
-				tlsMinProtoVer = auth.TlsParameters_TLSv1_3
-			case "1.2":
-				tlsMinProtoVer = auth.TlsParameters_TLSv1_2
-			default:
-				// any other value is interpreted as TLS/1.1
-				tlsMinProtoVer = auth.TlsParameters_TLSv1_1
+		cv := vh.ClientValidation
+		if cv != nil && cv.CACertificate == nil {
+			// the CA certificate is missing, do not serve
+			// this virtual host without verifying clients.
+			return
+		}
+		var hosts []string
+		if vh.Host != "*" {
+			// the default secure virtual host matches any name.
+			hosts = append(hosts, vh.Host)
+		}
+		hosts = append(hosts, vh.Aliases...)
+		var names []string
+		for _, h := range hosts {
+			if !sni[h] {
+				sni[h] = true
+				names = append(names, h)
 			}
-			fc := listener.FilterChain{
-				FilterChainMatch: &listener.FilterChainMatch{
-					SniDomains: tls.Hosts,
+		}
+		if len(hosts) > 0 && len(names) == 0 {
+			// every name is already served by another filter chain.
+			return
+		}
+		fc := listener.FilterChain{
+			FilterChainMatch: &listener.FilterChainMatch{
+				SniDomains: names,
+			},
+			TlsContext: tlscontext(vh.Secret.Object, vh.MinProtoVersion, "h2", "http/1.1"),
+			Filters:    filters,
+		}
+		if cv != nil {
+			fc.TlsContext.CommonTlsContext.ValidationContext = &auth.CertificateValidationContext{
+				TrustedCa: &core.DataSource{
+					Specifier: &core.DataSource_InlineBytes{
+						InlineBytes: cv.CACertificate.Object.Data[dag.CACertificateKey],
+					},
 				},
-				TlsContext: tlscontext(secret, tlsMinProtoVer, "h2", "http/1.1"),
-				Filters:    filters,
 			}
-			if lc.UseProxyProto {
-				fc.UseProxyProto = &types.BoolValue{Value: true}
+			fc.TlsContext.RequireClientCertificate = &types.BoolValue{Value: true}
+			// only this virtual host is reachable through this filter chain.
+			f := httpfilter(ClientValidatedRouteName(hashname(60, vh.Host)), lc.httpsAccessLog(), lc.UseADS)
+			if cv.ForwardClientCertificate {
+				f = forwardclientcert(f)
 			}
-			l.FilterChains = append(l.FilterChains, fc)
+			fc.Filters = []listener.Filter{f}
 		}
-	}
+		if lc.UseProxyProto {
+			fc.UseProxyProto = &types.BoolValue{Value: true}
+		}
+		l.FilterChains = append(l.FilterChains, fc)
+	})
 
-	switch len(l.FilterChains) {
-	case 0:
-		// no tls ingresses registered, remove the listener
-		return nil, []string{l.Name}
-	default:
-		// at least one tls ingress registered, refresh listener
-		return []*v2.Listener{l}, nil
+	if len(l.FilterChains) == 0 {
+		// no tls virtual hosts registered, remove the listener
+		return nil
 	}
+	return l
 }
 
 // httpsAddress returns the port for the HTTPS (TLS)
@@ -296,17 +255,6 @@ func (lc *ListenerCache) httpsAccessLog() string {
 	return DEFAULT_HTTPS_ACCESS_LOG
 }
 
-// validTLSIngress returns true if this is a valid ssl ingress object.
-// ingresses are invalid if they contain annotations, or are missing information
-// which excludes them from the ingress_https listener.
-func validTLSIngress(i *v1beta1.Ingress) bool {
-	if len(i.Spec.TLS) == 0 {
-		// this ingress does not use TLS, skip it
-		return false
-	}
-	return true
-}
-
 func socketaddress(address string, port uint32) core.Address {
 	return core.Address{
 		Address: &core.Address_SocketAddress{
@@ -344,7 +292,32 @@ func tlscontext(secret *v1.Secret, tlsMinProtoVersion auth.TlsParameters_TlsProt
 	}
 }
 
-func httpfilter(routename, accessLogPath string) listener.Filter {
+// rdsconfigsource returns the config source from which Envoy
+// retrieves RDS resources, over ADS if ads is true.
+func rdsconfigsource(ads bool) *types.Value {
+	if ads {
+		return st(map[string]*types.Value{
+			"ads": st(map[string]*types.Value{}),
+		})
+	}
+	return st(map[string]*types.Value{
+		"api_config_source": st(map[string]*types.Value{
+			"api_type": sv("GRPC"),
+			"cluster_names": lv(
+				sv("contour"),
+			),
+			"grpc_services": lv(
+				st(map[string]*types.Value{
+					"envoy_grpc": st(map[string]*types.Value{
+						"cluster_name": sv("contour"),
+					}),
+				}),
+			),
+		}),
+	})
+}
+
+func httpfilter(routename, accessLogPath string, ads bool) listener.Filter {
 	return listener.Filter{
 		Name: httpFilter,
 		Config: &types.Struct{
@@ -352,21 +325,7 @@ func httpfilter(routename, accessLogPath string) listener.Filter {
 				"stat_prefix": sv(routename),
 				"rds": st(map[string]*types.Value{
 					"route_config_name": sv(routename),
-					"config_source": st(map[string]*types.Value{
-						"api_config_source": st(map[string]*types.Value{
-							"api_type": sv("GRPC"),
-							"cluster_names": lv(
-								sv("contour"),
-							),
-							"grpc_services": lv(
-								st(map[string]*types.Value{
-									"envoy_grpc": st(map[string]*types.Value{
-										"cluster_name": sv("contour"),
-									}),
-								}),
-							),
-						}),
-					}),
+					"config_source":     rdsconfigsource(ads),
 				}),
 				"http_filters": lv(
 					st(map[string]*types.Value{
@@ -383,6 +342,18 @@ func httpfilter(routename, accessLogPath string) listener.Filter {
 	}
 }
 
+// forwardclientcert configures the http connection manager filter f to
+// forward the details of the client certificate to upstreams in the
+// x-forwarded-client-cert header, replacing any sent by the client.
+func forwardclientcert(f listener.Filter) listener.Filter {
+	f.Config.Fields["forward_client_cert_details"] = sv("SANITIZE_SET")
+	f.Config.Fields["set_current_client_cert_details"] = st(map[string]*types.Value{
+		"subject": bv(true),
+		"san":     bv(true),
+	})
+	return f
+}
+
 func accesslog(path string) *types.Value {
 	return lv(
 		st(map[string]*types.Value{
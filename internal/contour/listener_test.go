@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
+	"github.com/heptio/contour/internal/dag"
 	"k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -25,6 +26,7 @@ import (
 
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
 	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
@@ -33,14 +35,11 @@ import (
 func TestRecomputeListener(t *testing.T) {
 	tests := map[string]*struct {
 		ingresses map[metadata]*v1beta1.Ingress
-		add       []*v2.Listener
-		remove    []string
+		want      *v2.Listener
 		ListenerCache
 	}{
 		"empty ingress map": {
 			ingresses: nil,
-			add:       nil,
-			remove:    []string{ENVOY_HTTP_LISTENER},
 		},
 		"default vhost ingress": {
 			ingresses: map[metadata]*v1beta1.Ingress{
@@ -54,14 +53,13 @@ func TestRecomputeListener(t *testing.T) {
 					},
 				},
 			},
-			add: []*v2.Listener{{
+			want: &v2.Listener{
 				Name:    ENVOY_HTTP_LISTENER,
 				Address: socketaddress("0.0.0.0", 8080),
 				FilterChains: []listener.FilterChain{
-					filterchain(false, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG)),
+					filterchain(false, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, false)),
 				},
-			}},
-			remove: nil,
+			},
 		},
 		// setting kubernetes.io/ingress.allow-http: "false" should remove this
 		// ingress from consideration, leading to listener removal.
@@ -80,8 +78,6 @@ func TestRecomputeListener(t *testing.T) {
 					},
 				},
 			},
-			add:    nil,
-			remove: []string{ENVOY_HTTP_LISTENER},
 		},
 		// http listener on non default port.
 		"issue#72": {
@@ -100,14 +96,13 @@ func TestRecomputeListener(t *testing.T) {
 					},
 				},
 			},
-			add: []*v2.Listener{{
+			want: &v2.Listener{
 				Name:    ENVOY_HTTP_LISTENER,
 				Address: socketaddress("127.0.0.1", 9000),
 				FilterChains: []listener.FilterChain{
-					filterchain(false, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG)),
+					filterchain(false, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, false)),
 				},
-			}},
-			remove: nil,
+			},
 		},
 		"use proxy protocol": {
 			ListenerCache: ListenerCache{
@@ -124,40 +119,57 @@ func TestRecomputeListener(t *testing.T) {
 					},
 				},
 			},
-			add: []*v2.Listener{{
+			want: &v2.Listener{
 				Name:    ENVOY_HTTP_LISTENER,
 				Address: socketaddress("0.0.0.0", 8080),
 				FilterChains: []listener.FilterChain{
-					filterchain(true, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG)),
+					filterchain(true, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, false)),
 				},
-			}},
-			remove: nil,
+			},
+		},
+		"use ads": {
+			ListenerCache: ListenerCache{
+				UseADS: true,
+			},
+			ingresses: map[metadata]*v1beta1.Ingress{
+				metadata{namespace: "default", name: "simple"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: v1beta1.IngressSpec{
+						Backend: backend("backend", intstr.FromInt(80)),
+					},
+				},
+			},
+			want: &v2.Listener{
+				Name:    ENVOY_HTTP_LISTENER,
+				Address: socketaddress("0.0.0.0", 8080),
+				FilterChains: []listener.FilterChain{
+					filterchain(false, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, true)),
+				},
+			},
 		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			add, remove := tc.recomputeListener0(tc.ingresses)
-			if !reflect.DeepEqual(add, tc.add) {
-				t.Errorf("add:\n\texpected: %v\n\tgot: %v", tc.add, add)
-			}
-			if !reflect.DeepEqual(remove, tc.remove) {
-				t.Errorf("remove:\n\texpected: %v,\n\tgot: %v", tc.remove, remove)
+			got := tc.httpListener(listenerDAG(tc.ingresses, nil, nil))
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("want:\n%v\ngot:\n%v", tc.want, got)
 			}
 		})
 	}
 }
 func TestRecomputeListenerIngressRoute(t *testing.T) {
 	tests := map[string]*struct {
-		routes map[metadata]*ingressroutev1.IngressRoute
-		add    []*v2.Listener
-		remove []string
+		ingresses map[metadata]*v1beta1.Ingress
+		routes    map[metadata]*ingressroutev1.IngressRoute
+		want      *v2.Listener
 		ListenerCache
 	}{
 		"empty ingress map": {
 			routes: nil,
-			add:    nil,
-			remove: []string{ENVOY_HTTP_LISTENER},
 		},
 		"default vhost ingress": {
 			routes: map[metadata]*ingressroutev1.IngressRoute{
@@ -180,14 +192,13 @@ func TestRecomputeListenerIngressRoute(t *testing.T) {
 					},
 				},
 			},
-			add: []*v2.Listener{{
+			want: &v2.Listener{
 				Name:    ENVOY_HTTP_LISTENER,
 				Address: socketaddress("0.0.0.0", 8080),
 				FilterChains: []listener.FilterChain{
-					filterchain(false, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG)),
+					filterchain(false, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, false)),
 				},
-			}},
-			remove: nil,
+			},
 		},
 		// http listener on non default port.
 		"issue#72": {
@@ -215,14 +226,13 @@ func TestRecomputeListenerIngressRoute(t *testing.T) {
 					},
 				},
 			},
-			add: []*v2.Listener{{
+			want: &v2.Listener{
 				Name:    ENVOY_HTTP_LISTENER,
 				Address: socketaddress("127.0.0.1", 9000),
 				FilterChains: []listener.FilterChain{
-					filterchain(false, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG)),
+					filterchain(false, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, false)),
 				},
-			}},
-			remove: nil,
+			},
 		},
 		"use proxy protocol": {
 			ListenerCache: ListenerCache{
@@ -248,25 +258,49 @@ func TestRecomputeListenerIngressRoute(t *testing.T) {
 					},
 				},
 			},
-			add: []*v2.Listener{{
+			want: &v2.Listener{
 				Name:    ENVOY_HTTP_LISTENER,
 				Address: socketaddress("0.0.0.0", 8080),
 				FilterChains: []listener.FilterChain{
-					filterchain(true, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG)),
+					filterchain(true, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, false)),
 				},
-			}},
-			remove: nil,
+			},
+		},
+		// an ingress which does not allow http must not remove the
+		// listener required by an ingressroute.
+		"ingress and ingressroute": {
+			ingresses: map[metadata]*v1beta1.Ingress{
+				metadata{namespace: "default", name: "simple"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+						Annotations: map[string]string{
+							"kubernetes.io/ingress.allow-http": "false",
+						},
+					},
+					Spec: v1beta1.IngressSpec{
+						Backend: backend("backend", intstr.FromInt(80)),
+					},
+				},
+			},
+			routes: map[metadata]*ingressroutev1.IngressRoute{
+				metadata{namespace: "default", name: "simple"}: ingressroute("default", "simple", "example.com", irroute("/", "backend", 80)),
+			},
+			want: &v2.Listener{
+				Name:    ENVOY_HTTP_LISTENER,
+				Address: socketaddress("0.0.0.0", 8080),
+				FilterChains: []listener.FilterChain{
+					filterchain(false, httpfilter(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, false)),
+				},
+			},
 		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			add, remove := tc.recomputeListenerIngressRoute0(tc.routes)
-			if !reflect.DeepEqual(add, tc.add) {
-				t.Errorf("add:\n\texpected: %v\n\tgot: %v", tc.add, add)
-			}
-			if !reflect.DeepEqual(remove, tc.remove) {
-				t.Errorf("remove:\n\texpected: %v,\n\tgot: %v", tc.remove, remove)
+			got := tc.httpListener(listenerDAG(tc.ingresses, tc.routes, nil))
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("want:\n%v\ngot:\n%v", tc.want, got)
 			}
 		})
 	}
@@ -275,16 +309,14 @@ func TestRecomputeListenerIngressRoute(t *testing.T) {
 func TestRecomputeTLSListener(t *testing.T) {
 	tests := map[string]*struct {
 		ingresses map[metadata]*v1beta1.Ingress
+		routes    map[metadata]*ingressroutev1.IngressRoute
 		secrets   map[metadata]*v1.Secret
-		add       []*v2.Listener
-		remove    []string
+		want      *v2.Listener
 		ListenerCache
 	}{
 		"empty ingress map": {
 			ingresses: nil,
 			secrets:   nil,
-			add:       nil,
-			remove:    []string{ENVOY_HTTPS_LISTENER},
 		},
 		// tls is not possible for the default backend vhost because it has no name.
 		"default vhost ingress": {
@@ -300,8 +332,6 @@ func TestRecomputeTLSListener(t *testing.T) {
 				},
 			},
 			secrets: nil,
-			add:     nil,
-			remove:  []string{ENVOY_HTTPS_LISTENER},
 		},
 		"simple vhost, with no secret": {
 			ingresses: map[metadata]*v1beta1.Ingress{
@@ -320,8 +350,6 @@ func TestRecomputeTLSListener(t *testing.T) {
 				},
 			},
 			secrets: nil,
-			add:     nil,
-			remove:  []string{ENVOY_HTTPS_LISTENER},
 		},
 		"simple vhost, with secret": {
 			ingresses: map[metadata]*v1beta1.Ingress{
@@ -348,7 +376,7 @@ func TestRecomputeTLSListener(t *testing.T) {
 					Data: secretdata("certificate", "key"),
 				},
 			},
-			add: []*v2.Listener{{
+			want: &v2.Listener{
 				Name:    ENVOY_HTTPS_LISTENER,
 				Address: socketaddress("0.0.0.0", 8443),
 				FilterChains: []listener.FilterChain{{
@@ -359,11 +387,10 @@ func TestRecomputeTLSListener(t *testing.T) {
 						Data: secretdata("certificate", "key"),
 					}, auth.TlsParameters_TLSv1_1, "h2", "http/1.1"),
 					Filters: []listener.Filter{
-						httpfilter(ENVOY_HTTPS_LISTENER, DEFAULT_HTTPS_ACCESS_LOG),
+						httpfilter(ENVOY_HTTPS_LISTENER, DEFAULT_HTTPS_ACCESS_LOG, false),
 					},
 				}},
-			}},
-			remove: nil,
+			},
 		},
 		"simple vhost, with secret missing private key": {
 			ingresses: map[metadata]*v1beta1.Ingress{
@@ -393,8 +420,6 @@ func TestRecomputeTLSListener(t *testing.T) {
 					},
 				},
 			},
-			add:    nil,
-			remove: []string{ENVOY_HTTPS_LISTENER},
 		},
 		"simple vhost, with non default listener port": {
 			ListenerCache: ListenerCache{
@@ -425,7 +450,7 @@ func TestRecomputeTLSListener(t *testing.T) {
 					Data: secretdata("certificate", "key"),
 				},
 			},
-			add: []*v2.Listener{{
+			want: &v2.Listener{
 				Name:    ENVOY_HTTPS_LISTENER,
 				Address: socketaddress("::", 9000),
 				FilterChains: []listener.FilterChain{{
@@ -436,11 +461,10 @@ func TestRecomputeTLSListener(t *testing.T) {
 						Data: secretdata("certificate", "key"),
 					}, auth.TlsParameters_TLSv1_1, "h2", "http/1.1"),
 					Filters: []listener.Filter{
-						httpfilter(ENVOY_HTTPS_LISTENER, DEFAULT_HTTPS_ACCESS_LOG),
+						httpfilter(ENVOY_HTTPS_LISTENER, DEFAULT_HTTPS_ACCESS_LOG, false),
 					},
 				}},
-			}},
-			remove: nil,
+			},
 		},
 		"use proxy protocol": {
 			ListenerCache: ListenerCache{
@@ -473,7 +497,7 @@ func TestRecomputeTLSListener(t *testing.T) {
 					},
 				},
 			},
-			add: []*v2.Listener{{
+			want: &v2.Listener{
 				Name:    ENVOY_HTTPS_LISTENER,
 				Address: socketaddress("0.0.0.0", 8443),
 				FilterChains: []listener.FilterChain{{
@@ -484,11 +508,11 @@ func TestRecomputeTLSListener(t *testing.T) {
 						Data: secretdata("certificate", "key"),
 					}, auth.TlsParameters_TLSv1_1, "h2", "http/1.1"),
 					Filters: []listener.Filter{
-						httpfilter(ENVOY_HTTPS_LISTENER, DEFAULT_HTTPS_ACCESS_LOG),
+						httpfilter(ENVOY_HTTPS_LISTENER, DEFAULT_HTTPS_ACCESS_LOG, false),
 					},
 					UseProxyProto: &types.BoolValue{Value: true},
 				}},
-			}},
+			},
 		},
 		"simple vhost, with minimum TLS version annotation": {
 			ingresses: map[metadata]*v1beta1.Ingress{
@@ -518,7 +542,7 @@ func TestRecomputeTLSListener(t *testing.T) {
 					Data: secretdata("certificate", "key"),
 				},
 			},
-			add: []*v2.Listener{{
+			want: &v2.Listener{
 				Name:    ENVOY_HTTPS_LISTENER,
 				Address: socketaddress("0.0.0.0", 8443),
 				FilterChains: []listener.FilterChain{{
@@ -529,22 +553,226 @@ func TestRecomputeTLSListener(t *testing.T) {
 						Data: secretdata("certificate", "key"),
 					}, auth.TlsParameters_TLSv1_3, "h2", "http/1.1"),
 					Filters: []listener.Filter{
-						httpfilter(ENVOY_HTTPS_LISTENER, DEFAULT_HTTPS_ACCESS_LOG),
+						httpfilter(ENVOY_HTTPS_LISTENER, DEFAULT_HTTPS_ACCESS_LOG, false),
 					},
 				}},
-			}},
-			remove: nil,
+			},
+		},
+		"ingressroute, with no secret": {
+			routes: map[metadata]*ingressroutev1.IngressRoute{
+				metadata{namespace: "default", name: "simple"}: tlsingressroute("default", "simple", "whatever.example.com", "missing"),
+			},
+			secrets: nil,
+		},
+		"ingressroute, with secret in another namespace": {
+			routes: map[metadata]*ingressroutev1.IngressRoute{
+				metadata{namespace: "default", name: "simple"}: tlsingressroute("default", "simple", "whatever.example.com", "secret"),
+			},
+			secrets: map[metadata]*v1.Secret{
+				metadata{namespace: "other", name: "secret"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "secret",
+						Namespace: "other",
+					},
+					Data: secretdata("certificate", "key"),
+				},
+			},
+		},
+		"ingressroute, with secret and aliases": {
+			routes: map[metadata]*ingressroutev1.IngressRoute{
+				metadata{namespace: "default", name: "simple"}: tlsingressroute("default", "simple", "whatever.example.com", "secret", "www.example.com"),
+			},
+			secrets: map[metadata]*v1.Secret{
+				metadata{namespace: "default", name: "secret"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "secret",
+						Namespace: "default",
+					},
+					Data: secretdata("certificate", "key"),
+				},
+			},
+			want: &v2.Listener{
+				Name:    ENVOY_HTTPS_LISTENER,
+				Address: socketaddress("0.0.0.0", 8443),
+				FilterChains: []listener.FilterChain{{
+					FilterChainMatch: &listener.FilterChainMatch{
+						SniDomains: []string{"whatever.example.com", "www.example.com"},
+					},
+					TlsContext: tlscontext(&v1.Secret{
+						Data: secretdata("certificate", "key"),
+					}, auth.TlsParameters_TLSv1_1, "h2", "http/1.1"),
+					Filters: []listener.Filter{
+						httpfilter(ENVOY_HTTPS_LISTENER, DEFAULT_HTTPS_ACCESS_LOG, false),
+					},
+				}},
+			},
+		},
+		"ingress and ingressroute": {
+			ingresses: map[metadata]*v1beta1.Ingress{
+				metadata{namespace: "default", name: "simple"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: v1beta1.IngressSpec{
+						TLS: []v1beta1.IngressTLS{{
+							Hosts:      []string{"whatever.example.com"},
+							SecretName: "secret",
+						}},
+						Backend: backend("backend", intstr.FromInt(80)),
+					},
+				},
+			},
+			routes: map[metadata]*ingressroutev1.IngressRoute{
+				metadata{namespace: "default", name: "simple"}: tlsingressroute("default", "simple", "other.example.com", "other"),
+				// whatever.example.com is already served by the secret of the ingress.
+				metadata{namespace: "default", name: "duplicate"}: tlsingressroute("default", "duplicate", "whatever.example.com", "other"),
+			},
+			secrets: map[metadata]*v1.Secret{
+				metadata{namespace: "default", name: "secret"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "secret",
+						Namespace: "default",
+					},
+					Data: secretdata("certificate", "key"),
+				},
+				metadata{namespace: "default", name: "other"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "other",
+						Namespace: "default",
+					},
+					Data: secretdata("othercertificate", "otherkey"),
+				},
+			},
+			want: &v2.Listener{
+				Name:    ENVOY_HTTPS_LISTENER,
+				Address: socketaddress("0.0.0.0", 8443),
+				// filter chains are ordered by virtual host.
+				FilterChains: []listener.FilterChain{{
+					FilterChainMatch: &listener.FilterChainMatch{
+						SniDomains: []string{"other.example.com"},
+					},
+					TlsContext: tlscontext(&v1.Secret{
+						Data: secretdata("othercertificate", "otherkey"),
+					}, auth.TlsParameters_TLSv1_1, "h2", "http/1.1"),
+					Filters: []listener.Filter{
+						httpfilter(ENVOY_HTTPS_LISTENER, DEFAULT_HTTPS_ACCESS_LOG, false),
+					},
+				}, {
+					FilterChainMatch: &listener.FilterChainMatch{
+						SniDomains: []string{"whatever.example.com"},
+					},
+					TlsContext: tlscontext(&v1.Secret{
+						Data: secretdata("certificate", "key"),
+					}, auth.TlsParameters_TLSv1_1, "h2", "http/1.1"),
+					Filters: []listener.Filter{
+						httpfilter(ENVOY_HTTPS_LISTENER, DEFAULT_HTTPS_ACCESS_LOG, false),
+					},
+				}},
+			},
+		},
+		"client validation": {
+			ingresses: map[metadata]*v1beta1.Ingress{
+				metadata{namespace: "default", name: "simple"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+						Annotations: map[string]string{
+							"contour.heptio.com/tls-client-ca-secret":    "ca",
+							"contour.heptio.com/tls-forward-client-cert": "true",
+						},
+					},
+					Spec: v1beta1.IngressSpec{
+						TLS: []v1beta1.IngressTLS{{
+							Hosts:      []string{"whatever.example.com"},
+							SecretName: "secret",
+						}},
+						Backend: backend("backend", intstr.FromInt(80)),
+					},
+				},
+			},
+			secrets: map[metadata]*v1.Secret{
+				metadata{namespace: "default", name: "secret"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "secret",
+						Namespace: "default",
+					},
+					Data: secretdata("certificate", "key"),
+				},
+				metadata{namespace: "default", name: "ca"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "ca",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"ca.crt": []byte("ca"),
+					},
+				},
+			},
+			want: &v2.Listener{
+				Name:    ENVOY_HTTPS_LISTENER,
+				Address: socketaddress("0.0.0.0", 8443),
+				FilterChains: []listener.FilterChain{{
+					FilterChainMatch: &listener.FilterChainMatch{
+						SniDomains: []string{"whatever.example.com"},
+					},
+					TlsContext: func() *auth.DownstreamTlsContext {
+						tc := tlscontext(&v1.Secret{
+							Data: secretdata("certificate", "key"),
+						}, auth.TlsParameters_TLSv1_1, "h2", "http/1.1")
+						tc.CommonTlsContext.ValidationContext = &auth.CertificateValidationContext{
+							TrustedCa: &core.DataSource{
+								Specifier: &core.DataSource_InlineBytes{
+									InlineBytes: []byte("ca"),
+								},
+							},
+						}
+						tc.RequireClientCertificate = &types.BoolValue{Value: true}
+						return tc
+					}(),
+					Filters: []listener.Filter{
+						forwardclientcert(httpfilter("ingress_https/whatever.example.com", DEFAULT_HTTPS_ACCESS_LOG, false)),
+					},
+				}},
+			},
+		},
+		"client validation, ca secret missing": {
+			ingresses: map[metadata]*v1beta1.Ingress{
+				metadata{namespace: "default", name: "simple"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+						Annotations: map[string]string{
+							"contour.heptio.com/tls-client-ca-secret": "ca",
+						},
+					},
+					Spec: v1beta1.IngressSpec{
+						TLS: []v1beta1.IngressTLS{{
+							Hosts:      []string{"whatever.example.com"},
+							SecretName: "secret",
+						}},
+						Backend: backend("backend", intstr.FromInt(80)),
+					},
+				},
+			},
+			secrets: map[metadata]*v1.Secret{
+				metadata{namespace: "default", name: "secret"}: {
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "secret",
+						Namespace: "default",
+					},
+					Data: secretdata("certificate", "key"),
+				},
+			},
+			want: nil,
 		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			add, remove := tc.recomputeTLSListener0(tc.ingresses, tc.secrets)
-			if !reflect.DeepEqual(add, tc.add) {
-				t.Errorf("add:\n\texpected: %v\n\tgot: %v", tc.add, add)
-			}
-			if !reflect.DeepEqual(remove, tc.remove) {
-				t.Errorf("remove:\n\texpected: %v,\n\tgot: %v", tc.remove, remove)
+			got := tc.httpsListener(listenerDAG(tc.ingresses, tc.routes, tc.secrets))
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("want:\n%v\ngot:\n%v", tc.want, got)
 			}
 		})
 	}
@@ -565,7 +793,7 @@ func TestListenerCacheRecomputeListener(t *testing.T) {
 			},
 		},
 	}
-	lc.recomputeListeners(i, nil)
+	lc.recompute(listenerDAG(i, nil, nil))
 	assertCacheNotEmpty(t, lc)
 }
 func TestListenerCacheRecomputeListenerIngressRoute(t *testing.T) {
@@ -592,7 +820,7 @@ func TestListenerCacheRecomputeListenerIngressRoute(t *testing.T) {
 			},
 		},
 	}
-	lc.recomputeListenersIngressRoute(i, nil)
+	lc.recompute(listenerDAG(nil, i, nil))
 	assertCacheNotEmpty(t, lc)
 }
 
@@ -605,6 +833,9 @@ func TestListenerCacheRecomputeTLSListener(t *testing.T) {
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      "simple",
 				Namespace: "default",
+				Annotations: map[string]string{
+					"kubernetes.io/ingress.allow-http": "false",
+				},
 			},
 			Spec: v1beta1.IngressSpec{
 				Backend: backend("backend", intstr.FromInt(80)),
@@ -612,13 +843,16 @@ func TestListenerCacheRecomputeTLSListener(t *testing.T) {
 		},
 	}
 	s := make(map[metadata]*v1.Secret)
-	lc.recomputeTLSListener(i, s)
+	lc.recompute(listenerDAG(i, nil, s))
 	assertCacheEmpty(t, lc) // expect cache to be empty, this is not a tls enabled ingress
 
 	i[metadata{name: "example", namespace: "default"}] = &v1beta1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "simple",
 			Namespace: "default",
+			Annotations: map[string]string{
+				"kubernetes.io/ingress.allow-http": "false",
+			},
 		},
 		Spec: v1beta1.IngressSpec{
 			TLS: []v1beta1.IngressTLS{{
@@ -628,7 +862,7 @@ func TestListenerCacheRecomputeTLSListener(t *testing.T) {
 			Backend: backend("backend", intstr.FromInt(80)),
 		},
 	}
-	lc.recomputeTLSListener(i, s)
+	lc.recompute(listenerDAG(i, nil, s))
 	assertCacheEmpty(t, lc) // expect cache to be empty, this ingress is tls enabled, but missing secret
 
 	s[metadata{name: "secret", namespace: "default"}] = &v1.Secret{
@@ -638,75 +872,10 @@ func TestListenerCacheRecomputeTLSListener(t *testing.T) {
 		},
 		Data: secretdata("certificate", "key"),
 	}
-	lc.recomputeTLSListener(i, s)
+	lc.recompute(listenerDAG(i, nil, s))
 	assertCacheNotEmpty(t, lc) // we've got the secret and the ingress, we should have at least one listener
 }
 
-func TestValidTLSIngress(t *testing.T) {
-	tests := map[string]struct {
-		i     *v1beta1.Ingress
-		valid bool
-	}{
-		"non tls ingress": {
-			i: &v1beta1.Ingress{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "simple",
-					Namespace: "default",
-				},
-				Spec: v1beta1.IngressSpec{
-					Backend: backend("backend", intstr.FromInt(80)),
-				},
-			},
-			valid: false,
-		},
-		"tls ingress": {
-			i: &v1beta1.Ingress{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "simple",
-					Namespace: "default",
-				},
-				Spec: v1beta1.IngressSpec{
-					TLS: []v1beta1.IngressTLS{{
-						Hosts:      []string{"whatever.example.com"},
-						SecretName: "secret",
-					}},
-					Backend: backend("backend", intstr.FromInt(80)),
-				},
-			},
-			valid: true,
-		},
-		"kubernetes.io/ingress.allow-http: \"false\"": {
-			i: &v1beta1.Ingress{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "simple",
-					Namespace: "default",
-					Annotations: map[string]string{
-						"kubernetes.io/ingress.allow-http": "false",
-					},
-				},
-				Spec: v1beta1.IngressSpec{
-					TLS: []v1beta1.IngressTLS{{
-						Hosts:      []string{"whatever.example.com"},
-						SecretName: "secret",
-					}},
-					Backend: backend("backend", intstr.FromInt(80)),
-				},
-			},
-			valid: true,
-		},
-	}
-
-	for name, tc := range tests {
-		t.Run(name, func(t *testing.T) {
-			got := validTLSIngress(tc.i)
-			want := tc.valid
-			if got != want {
-				t.Fatalf("validTLSIngress: got: %v, want: %v", got, want)
-			}
-		})
-	}
-}
-
 func assertCacheEmpty(t *testing.T, lc *ListenerCache) {
 	t.Helper()
 	if len(contents(lc)) > 0 {
@@ -721,6 +890,21 @@ func assertCacheNotEmpty(t *testing.T, lc *ListenerCache) {
 	}
 }
 
+// listenerDAG returns a DAG built from the supplied ingresses, ingressroutes, and secrets.
+func listenerDAG(ingresses map[metadata]*v1beta1.Ingress, routes map[metadata]*ingressroutev1.IngressRoute, secrets map[metadata]*v1.Secret) *dag.DAG {
+	var objs []interface{}
+	for _, i := range ingresses {
+		objs = append(objs, i)
+	}
+	for _, r := range routes {
+		objs = append(objs, r)
+	}
+	for _, s := range secrets {
+		objs = append(objs, s)
+	}
+	return buildDAG(objs...)
+}
+
 func secretdata(cert, key string) map[string][]byte {
 	return map[string][]byte{
 		v1.TLSCertKey:       []byte(cert),
@@ -728,6 +912,13 @@ func secretdata(cert, key string) map[string][]byte {
 	}
 }
 
+func tlsingressroute(ns, name, fqdn, secret string, aliases ...string) *ingressroutev1.IngressRoute {
+	ir := ingressroute(ns, name, fqdn, irroute("/", "backend", 80))
+	ir.Spec.VirtualHost.Aliases = aliases
+	ir.Spec.VirtualHost.TLS.SecretName = secret
+	return ir
+}
+
 type clusterLoadAssignmentsByName []proto.Message
 
 func (c clusterLoadAssignmentsByName) Len() int      { return len(c) }
@@ -0,0 +1,30 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+)
+
+// IngressRouteStatusWriter records the status of an IngressRoute.
+type IngressRouteStatusWriter interface {
+	SetStatus(status ingressroutev1.Status, existing *ingressroutev1.IngressRoute) error
+}
+
+// IngressStatusWriter records the load balancer status of an Ingress.
+type IngressStatusWriter interface {
+	SetLoadBalancer(lb v1.LoadBalancerStatus, existing *v1beta1.Ingress) error
+}
@@ -0,0 +1,598 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"reflect"
+	"testing"
+
+	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
+	"github.com/heptio/contour/internal/dag"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestIngressRouteStatuses(t *testing.T) {
+	kuard := service("default", "kuard", v1.ServicePort{
+		Protocol:   "TCP",
+		Port:       8080,
+		TargetPort: intstr.FromInt(8080),
+	})
+
+	tests := map[string]struct {
+		objs []interface{}
+		want map[metadata]ingressRouteStatus
+	}{
+		"valid root": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", irroute("/", "kuard", 8080)),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusValid, description: "valid IngressRoute"},
+			},
+		},
+		"missing service": {
+			objs: []interface{}{
+				ingressroute("default", "root", "example.com", irroute("/", "kuard", 8080)),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/": service default/kuard: port 8080 not found`},
+			},
+		},
+		"missing service port": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", irroute("/", "kuard", 9000)),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/": service default/kuard: port 9000 not found`},
+			},
+		},
+		"delegate of root with a missing service": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", irroute("/", "missing", 8080), irdelegate("/api", "child", "")),
+				ingressroute("default", "child", "", irroute("/api", "kuard", 8080)),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}:  {status: dag.StatusInvalid, description: `route "/": service default/missing: port 8080 not found`},
+				{name: "child", namespace: "default"}: {status: dag.StatusValid, description: "valid IngressRoute"},
+			},
+		},
+		"invalid fqdn": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "www.*.com", irroute("/", "kuard", 8080)),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `fqdn "www.*.com" is not valid`},
+			},
+		},
+		"duplicate fqdn": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "a", "example.com", irroute("/", "kuard", 8080)),
+				ingressroute("default", "b", "example.com", irroute("/", "kuard", 8080)),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "a", namespace: "default"}: {status: dag.StatusInvalid, description: `fqdn "example.com" is used in multiple IngressRoutes: default/a, default/b`},
+				{name: "b", namespace: "default"}: {status: dag.StatusInvalid, description: `fqdn "example.com" is used in multiple IngressRoutes: default/a, default/b`},
+			},
+		},
+		"delegated child": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", irdelegate("/", "child", "")),
+				ingressroute("default", "child", "", irroute("/", "kuard", 8080)),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}:  {status: dag.StatusValid, description: "valid IngressRoute"},
+				{name: "child", namespace: "default"}: {status: dag.StatusValid, description: "valid IngressRoute"},
+			},
+		},
+		"delegation cycle": {
+			objs: []interface{}{
+				ingressroute("default", "root", "example.com", irdelegate("/", "child", "")),
+				ingressroute("default", "child", "", irdelegate("/", "root", "")),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}:  {status: dag.StatusValid, description: "valid IngressRoute"},
+				{name: "child", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/": delegation to default/root creates a cycle`},
+			},
+		},
+		"ambiguous header condition": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", irheaders(irroute("/", "kuard", 8080), ingressroutev1.HeaderCondition{
+					Name:    "x-canary",
+					Exact:   "true",
+					Present: true,
+				})),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/": header "x-canary": exactly one of exact, regex, or present must be set`},
+			},
+		},
+		"inverted present header condition": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", irheaders(irroute("/", "kuard", 8080), ingressroutev1.HeaderCondition{
+					Name:    "x-canary",
+					Present: true,
+					Invert:  true,
+				})),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/": header "x-canary": present conditions cannot be inverted`},
+			},
+		},
+		"invalid regex match": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", irmatchtype(irroute("/api/(v1", "kuard", 8080), "regex")),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: "route \"/api/(v1\": invalid regex: error parsing regexp: missing closing ): `/api/(v1`"},
+			},
+		},
+		"lookahead regex match": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", irmatchtype(irroute("/api/(?!v1).*", "kuard", 8080), "regex")),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/api/(?!v1).*": invalid regex: lookahead "(?!" is not supported`},
+			},
+		},
+		"regex match with flags": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", irmatchtype(irroute("(?i)/api/.*", "kuard", 8080), "regex")),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "(?i)/api/.*": invalid regex: flags and named groups are not supported: "(?i)"`},
+			},
+		},
+		"backreference in header regex": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", irheaders(irroute("/", "kuard", 8080), ingressroutev1.HeaderCondition{
+					Name:  "x-pair",
+					Regex: `(\w+)=\1`,
+				})),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/": header "x-pair": invalid regex: backreference "\\1" is not supported`},
+			},
+		},
+		"delegation by exact match": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", irmatchtype(irdelegate("/api", "child", ""), "exact")),
+				ingressroute("default", "child", "", irroute("/api", "kuard", 8080)),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}:  {status: dag.StatusInvalid, description: `route "/api": delegation requires a prefix match, not exact`},
+				{name: "child", namespace: "default"}: {status: dag.StatusOrphaned, description: "this IngressRoute is not part of a delegation chain from a root IngressRoute"},
+			},
+		},
+		"prefix rewrite of regex match": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", ingressroutev1.Route{
+					Match:         "/api/.*",
+					MatchType:     "regex",
+					PrefixRewrite: "/",
+					Services: []ingressroutev1.Service{{
+						Name: "kuard",
+						Port: 8080,
+					}},
+				}),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/api/.*": prefixRewrite requires a prefix or exact match`},
+			},
+		},
+		"redirect": {
+			objs: []interface{}{
+				ingressroute("default", "root", "legacy.example.com", ingressroutev1.Route{
+					Match: "/",
+					Redirect: &ingressroutev1.Redirect{
+						Host:       "example.com",
+						StatusCode: 308,
+					},
+				}),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusValid, description: "valid IngressRoute"},
+			},
+		},
+		"redirect with services": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "root", "example.com", ingressroutev1.Route{
+					Match: "/",
+					Services: []ingressroutev1.Service{{
+						Name: "kuard",
+						Port: 8080,
+					}},
+					Redirect: &ingressroutev1.Redirect{
+						Host: "example.com",
+					},
+				}),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/": redirect cannot be combined with services, delegate, or prefixRewrite`},
+			},
+		},
+		"redirect to http": {
+			objs: []interface{}{
+				ingressroute("default", "root", "example.com", ingressroutev1.Route{
+					Match: "/",
+					Redirect: &ingressroutev1.Redirect{
+						Scheme: "http",
+					},
+				}),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/": redirect scheme "http" is not supported, only https`},
+			},
+		},
+		"redirect status code": {
+			objs: []interface{}{
+				ingressroute("default", "root", "example.com", ingressroutev1.Route{
+					Match: "/",
+					Redirect: &ingressroutev1.Redirect{
+						Path:       "/",
+						StatusCode: 303,
+					},
+				}),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/": redirect statusCode 303 is not one of 301, 302, 307, or 308`},
+			},
+		},
+		"orphaned by invalid root": {
+			objs: []interface{}{
+				kuard,
+				ingressroute("default", "a", "example.com", irdelegate("/", "child", "")),
+				ingressroute("default", "b", "example.com", irroute("/", "kuard", 8080)),
+				ingressroute("default", "child", "", irroute("/", "kuard", 8080)),
+			},
+			want: map[metadata]ingressRouteStatus{
+				{name: "a", namespace: "default"}:     {status: dag.StatusInvalid, description: `fqdn "example.com" is used in multiple IngressRoutes: default/a, default/b`},
+				{name: "b", namespace: "default"}:     {status: dag.StatusInvalid, description: `fqdn "example.com" is used in multiple IngressRoutes: default/a, default/b`},
+				{name: "child", namespace: "default"}: {status: dag.StatusOrphaned, description: "this IngressRoute is not part of a delegation chain from a root IngressRoute"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := make(map[metadata]ingressRouteStatus)
+			for _, st := range buildDAG(tc.objs...).Statuses() {
+				got[metadata{name: st.Object.Name, namespace: st.Object.Namespace}] = ingressRouteStatus{
+					status:      st.Status,
+					description: st.Description,
+				}
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("want:\n%+v\n got:\n%+v", tc.want, got)
+			}
+		})
+	}
+}
+
+type ingressRouteStatus struct {
+	status, description string
+}
+
+type statusWriter map[metadata]ingressRouteStatus
+
+func (s statusWriter) SetStatus(status ingressroutev1.Status, existing *ingressroutev1.IngressRoute) error {
+	s[metadata{name: existing.Name, namespace: existing.Namespace}] = ingressRouteStatus{status: status.CurrentStatus, description: status.Description}
+	return nil
+}
+
+// updateWriter records every status written to each IngressRoute.
+type updateWriter map[metadata][]ingressroutev1.Status
+
+func (u updateWriter) SetStatus(status ingressroutev1.Status, existing *ingressroutev1.IngressRoute) error {
+	md := metadata{name: existing.Name, namespace: existing.Namespace}
+	u[md] = append(u[md], status)
+	return nil
+}
+
+type loadBalancerWriter map[metadata]v1.LoadBalancerStatus
+
+func (l loadBalancerWriter) SetLoadBalancer(lb v1.LoadBalancerStatus, existing *v1beta1.Ingress) error {
+	l[metadata{name: existing.Name, namespace: existing.Namespace}] = lb
+	return nil
+}
+
+func TestTranslatorUpdateIngressRouteStatus(t *testing.T) {
+	sw := make(statusWriter)
+	tr := &Translator{
+		FieldLogger:        testLogger(t),
+		IngressRouteStatus: sw,
+	}
+	tr.OnAdd(ingressroute("default", "root", "example.com", irroute("/", "kuard", 8080)))
+	want := statusWriter{
+		{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/": service default/kuard: port 8080 not found`},
+	}
+	if !reflect.DeepEqual(want, sw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, sw)
+	}
+
+	// adding the missing service makes the root valid.
+	tr.OnAdd(service("default", "kuard", v1.ServicePort{
+		Protocol:   "TCP",
+		Port:       8080,
+		TargetPort: intstr.FromInt(8080),
+	}))
+	want = statusWriter{
+		{name: "root", namespace: "default"}: {status: dag.StatusValid, description: "valid IngressRoute"},
+	}
+	if !reflect.DeepEqual(want, sw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, sw)
+	}
+}
+
+func TestTranslatorStatusLeader(t *testing.T) {
+	sw := make(statusWriter)
+	tr := &Translator{
+		FieldLogger:        testLogger(t),
+		IngressRouteStatus: sw,
+		LeaderElection:     true,
+	}
+	tr.OnAdd(ingressroute("default", "root", "example.com", irroute("/", "kuard", 8080)))
+	want := statusWriter{}
+	if !reflect.DeepEqual(want, sw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, sw)
+	}
+
+	// once elected, the status observed while not leader is written.
+	tr.StartedLeading()
+	want = statusWriter{
+		{name: "root", namespace: "default"}: {status: dag.StatusInvalid, description: `route "/": service default/kuard: port 8080 not found`},
+	}
+	if !reflect.DeepEqual(want, sw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, sw)
+	}
+
+	// once leadership is lost, status is no longer written.
+	tr.StoppedLeading()
+	tr.OnAdd(service("default", "kuard", v1.ServicePort{
+		Protocol:   "TCP",
+		Port:       8080,
+		TargetPort: intstr.FromInt(8080),
+	}))
+	if !reflect.DeepEqual(want, sw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, sw)
+	}
+}
+
+func TestTranslatorStatusWaitForSync(t *testing.T) {
+	sw := make(statusWriter)
+	tr := &Translator{
+		FieldLogger:        testLogger(t),
+		IngressRouteStatus: sw,
+		WaitForSync:        true,
+	}
+	tr.OnAdd(ingressroute("default", "root", "example.com", irroute("/", "kuard", 8080)))
+	tr.OnAdd(service("default", "kuard", v1.ServicePort{
+		Protocol:   "TCP",
+		Port:       8080,
+		TargetPort: intstr.FromInt(8080),
+	}))
+	want := statusWriter{}
+	if !reflect.DeepEqual(want, sw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, sw)
+	}
+
+	// once synced, only the status of the complete view is written.
+	tr.Synced()
+	want = statusWriter{
+		{name: "root", namespace: "default"}: {status: dag.StatusValid, description: "valid IngressRoute"},
+	}
+	if !reflect.DeepEqual(want, sw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, sw)
+	}
+}
+
+func TestTranslatorPublishIngressStatus(t *testing.T) {
+	lw := make(loadBalancerWriter)
+	tr := &Translator{
+		FieldLogger:           testLogger(t),
+		IngressStatus:         lw,
+		EnvoyServiceName:      "contour",
+		EnvoyServiceNamespace: "heptio-contour",
+	}
+	tr.OnAdd(&v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1beta1.IngressSpec{
+			Backend: backend("kuard", intstr.FromInt(80)),
+		},
+	})
+	tr.OnAdd(&v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"kubernetes.io/ingress.class": "nginx",
+			},
+		},
+		Spec: v1beta1.IngressSpec{
+			Backend: backend("other", intstr.FromInt(80)),
+		},
+	})
+
+	envoy := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "contour",
+			Namespace: "heptio-contour",
+		},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{{IP: "192.0.2.1"}},
+			},
+		},
+	}
+	tr.OnAdd(envoy)
+
+	want := loadBalancerWriter{
+		{name: "kuard", namespace: "default"}: envoy.Status.LoadBalancer,
+	}
+	if !reflect.DeepEqual(want, lw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, lw)
+	}
+
+	// a static address takes precedence over the envoy service.
+	tr.StatusAddress = "ingress.example.com"
+	tr.OnAdd(&v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1beta1.IngressSpec{
+			Backend: backend("kuard", intstr.FromInt(80)),
+		},
+	})
+	want = loadBalancerWriter{
+		{name: "kuard", namespace: "default"}: {
+			Ingress: []v1.LoadBalancerIngress{{Hostname: "ingress.example.com"}},
+		},
+	}
+	if !reflect.DeepEqual(want, lw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, lw)
+	}
+}
+
+func TestTranslatorPublishIngressRouteStatus(t *testing.T) {
+	uw := make(updateWriter)
+	tr := &Translator{
+		FieldLogger:           testLogger(t),
+		IngressRouteStatus:    uw,
+		EnvoyServiceName:      "contour",
+		EnvoyServiceNamespace: "heptio-contour",
+		WaitForSync:           true,
+	}
+	envoy := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "contour",
+			Namespace: "heptio-contour",
+		},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{{IP: "192.0.2.1"}},
+			},
+		},
+	}
+	tr.OnAdd(envoy)
+	tr.OnAdd(service("default", "kuard", v1.ServicePort{
+		Protocol:   "TCP",
+		Port:       8080,
+		TargetPort: intstr.FromInt(8080),
+	}))
+	tr.OnAdd(ingressroute("default", "root", "example.com", irroute("/", "kuard", 8080)))
+	tr.Synced()
+
+	// the status and load balancer status are written in one update.
+	want := updateWriter{
+		{name: "root", namespace: "default"}: {{
+			CurrentStatus: dag.StatusValid,
+			Description:   "valid IngressRoute",
+			LoadBalancer:  envoy.Status.LoadBalancer,
+		}},
+	}
+	if !reflect.DeepEqual(want, uw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, uw)
+	}
+
+	// a rebuild which leaves the status unchanged writes nothing.
+	tr.OnAdd(service("default", "other", v1.ServicePort{
+		Protocol:   "TCP",
+		Port:       8080,
+		TargetPort: intstr.FromInt(8080),
+	}))
+	if !reflect.DeepEqual(want, uw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, uw)
+	}
+
+	// a changed status is written.
+	moved := *envoy
+	moved.Status.LoadBalancer = v1.LoadBalancerStatus{
+		Ingress: []v1.LoadBalancerIngress{{IP: "192.0.2.2"}},
+	}
+	tr.OnUpdate(envoy, &moved)
+	want[metadata{name: "root", namespace: "default"}] = append(want[metadata{name: "root", namespace: "default"}], ingressroutev1.Status{
+		CurrentStatus: dag.StatusValid,
+		Description:   "valid IngressRoute",
+		LoadBalancer:  moved.Status.LoadBalancer,
+	})
+	if !reflect.DeepEqual(want, uw) {
+		t.Fatalf("want:\n%+v\n got:\n%+v", want, uw)
+	}
+}
+
+func ingressroute(ns, name, fqdn string, routes ...ingressroutev1.Route) *ingressroutev1.IngressRoute {
+	return &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			VirtualHost: ingressroutev1.VirtualHost{
+				Fqdn: fqdn,
+			},
+			Routes: routes,
+		},
+	}
+}
+
+func irroute(match, service string, port int) ingressroutev1.Route {
+	return ingressroutev1.Route{
+		Match: match,
+		Services: []ingressroutev1.Service{{
+			Name: service,
+			Port: port,
+		}},
+	}
+}
+
+func irdelegate(match, name, ns string) ingressroutev1.Route {
+	return ingressroutev1.Route{
+		Match: match,
+		Delegate: ingressroutev1.Delegate{
+			Name:      name,
+			Namespace: ns,
+		},
+	}
+}
+
+func irheaders(r ingressroutev1.Route, headers ...ingressroutev1.HeaderCondition) ingressroutev1.Route {
+	r.Headers = headers
+	return r
+}
+
+func irmatchtype(r ingressroutev1.Route, matchType string) ingressroutev1.Route {
+	r.MatchType = matchType
+	return r
+}
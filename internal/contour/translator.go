@@ -19,12 +19,18 @@ package contour
 import (
 	"crypto/sha256"
 	"fmt"
+	"net"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	"github.com/sirupsen/logrus"
 
 	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
+	"github.com/heptio/contour/internal/dag"
+	"github.com/heptio/contour/internal/k8s"
+	"github.com/heptio/contour/internal/metrics"
 	"k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -52,89 +58,153 @@ type Translator struct {
 	// If not set, defaults to DEFAULT_INGRESS_CLASS.
 	IngressClass string
 
+	// IngressRouteStatus, if set, receives the status of each
+	// IngressRoute as it is recomputed.
+	IngressRouteStatus IngressRouteStatusWriter
+
+	// IngressStatus, if set, receives the load balancer status
+	// of each Ingress matching IngressClass.
+	IngressStatus IngressStatusWriter
+
+	// EnvoyServiceName and EnvoyServiceNamespace name the Service
+	// which exposes Envoy. If set, the load balancer status of this
+	// Service is published to Ingress and IngressRoute objects.
+	EnvoyServiceName      string
+	EnvoyServiceNamespace string
+
+	// StatusAddress, if set, is published as the load balancer
+	// address of Ingress and IngressRoute objects in preference to
+	// the status of the Envoy Service.
+	StatusAddress string
+
+	// LeaderElection, if true, holds back writing status to Ingress
+	// and IngressRoute objects unless this Contour is leader, that is
+	// between calls to StartedLeading and StoppedLeading, so replicas
+	// do not race to write it. If false, status is always written.
+	LeaderElection bool
+
+	// WaitForSync, if true, holds back rebuilding the xDS caches and
+	// writing status until Synced is called, so that neither is computed
+	// from a partial view of the cluster while the informers complete
+	// their initial list, and the caches are built once rather than once
+	// per object listed.
+	WaitForSync bool
+
+	// DefaultTLSSecret, if set, names the Secret whose certificate is
+	// served to TLS clients which send no SNI name, or a name matching
+	// no Ingress or IngressRoute.
+	DefaultTLSSecret k8s.FullName
+
+	// Metrics, if set, records the duration of each translation.
+	Metrics *metrics.Metrics
+
 	cache translatorCache
+
+	// lbStatus is the last known load balancer status of the Envoy Service.
+	lbStatus v1.LoadBalancerStatus
+
+	// statuses are the IngressRoute statuses found by the last rebuild.
+	statuses []dag.Status
+
+	// written holds the status last written to each IngressRoute, so
+	// a rebuild writes only the statuses which changed.
+	written map[metadata]ingressroutev1.Status
+
+	// synced is true once Synced has been called.
+	synced bool
+
+	// leading is true between calls to StartedLeading and StoppedLeading.
+	leading bool
 }
 
 func (t *Translator) OnAdd(obj interface{}) {
 	t.cache.OnAdd(obj)
 	switch obj := obj.(type) {
 	case *v1.Service:
-		t.addService(obj)
+		t.updateEnvoyService(obj)
 	case *v1beta1.Ingress:
-		t.addIngress(obj)
-		t.VirtualHostCache.Notify()
-	case *v1.Secret:
-		t.addSecret(obj)
-	case *ingressroutev1.IngressRoute:
-		t.addIngressRoute(obj)
+		t.publishIngressStatus(obj)
+	case *v1.Secret, *ingressroutev1.IngressRoute:
+		// nothing to do
 	default:
 		t.Errorf("OnAdd unexpected type %T: %#v", obj, obj)
+		return
 	}
+	t.rebuild()
 }
 
 func (t *Translator) OnUpdate(oldObj, newObj interface{}) {
 	t.cache.OnUpdate(oldObj, newObj)
-	// TODO(dfc) need to inspect oldObj and remove unused parts of the config from the cache.
 	switch newObj := newObj.(type) {
 	case *v1.Service:
-		oldObj, ok := oldObj.(*v1.Service)
-		if !ok {
-			t.Errorf("OnUpdate service %#v received invalid oldObj %T: %#v", newObj, oldObj, oldObj)
-			return
-		}
-		t.updateService(oldObj, newObj)
+		t.updateEnvoyService(newObj)
 	case *v1beta1.Ingress:
-		oldObj, ok := oldObj.(*v1beta1.Ingress)
-		if !ok {
-			t.Errorf("OnUpdate endpoints %#v received invalid oldObj %T; %#v", newObj, oldObj, oldObj)
-			return
-		}
-		t.updateIngress(oldObj, newObj)
-		t.VirtualHostCache.Notify()
-	case *v1.Secret:
-		t.addSecret(newObj)
-	case *ingressroutev1.IngressRoute:
-		oldObj, ok := oldObj.(*ingressroutev1.IngressRoute)
-		if !ok {
-			t.Errorf("OnUpdate ingressRoute %#v received invalid oldObj %T; %#v", newObj, oldObj, oldObj)
-			return
-		}
-		t.updateIngressRoute(oldObj, newObj)
-		t.VirtualHostCache.Notify()
+		t.publishIngressStatus(newObj)
+	case *v1.Secret, *ingressroutev1.IngressRoute:
+		// nothing to do
 	default:
 		t.Errorf("OnUpdate unexpected type %T: %#v", newObj, newObj)
+		return
 	}
+	t.rebuild()
 }
 
 func (t *Translator) OnDelete(obj interface{}) {
 	t.cache.OnDelete(obj)
 	switch obj := obj.(type) {
 	case *v1.Service:
-		t.removeService(obj)
-	case *v1beta1.Ingress:
-		t.removeIngress(obj)
-		t.VirtualHostCache.Notify()
-	case *v1.Secret:
-		t.removeSecret(obj)
+		t.updateEnvoyService(&v1.Service{ObjectMeta: obj.ObjectMeta})
+	case *v1beta1.Ingress, *v1.Secret, *ingressroutev1.IngressRoute:
+		// nothing to do
 	case _cache.DeletedFinalStateUnknown:
 		t.OnDelete(obj.Obj) // recurse into ourselves with the tombstoned value
-	case *ingressroutev1.IngressRoute:
-		t.removeIngressRoute(obj)
+		return
 	default:
 		t.Errorf("OnDelete unexpected type %T: %#v", obj, obj)
+		return
 	}
+	t.rebuild()
 }
 
-func (t *Translator) addService(svc *v1.Service) {
-	t.recomputeService(nil, svc)
-}
-
-func (t *Translator) updateService(oldsvc, newsvc *v1.Service) {
-	t.recomputeService(oldsvc, newsvc)
-}
+// rebuild builds a DAG from the contents of the translator's cache and
+// recomputes the CDS, LDS, and RDS caches, and the status of every
+// IngressRoute, from it. Watchers of each cache are only notified if
+// its contents changed.
+func (t *Translator) rebuild() {
+	if t.WaitForSync && !t.synced {
+		// built once by Synced.
+		return
+	}
+	start := time.Now()
+	defer func() {
+		t.Metrics.ObserveTranslation(time.Since(start))
+	}()
 
-func (t *Translator) removeService(svc *v1.Service) {
-	t.recomputeService(svc, nil)
+	b := dag.Builder{
+		DefaultSecret: t.DefaultTLSSecret,
+	}
+	for _, i := range t.cache.ingresses {
+		if t.matchesIngressClass(i) {
+			// if there is an ingress class set, but it is not set to configured
+			// or default ingress class, ignore this ingress.
+			b.Insert(i)
+		}
+	}
+	for _, ir := range t.cache.routes {
+		b.Insert(ir)
+	}
+	for _, s := range t.cache.services {
+		b.Insert(s)
+	}
+	for _, s := range t.cache.secrets {
+		b.Insert(s)
+	}
+	d := b.Build()
+	t.ClusterCache.recompute(d)
+	t.ListenerCache.recompute(d)
+	t.VirtualHostCache.recompute(d)
+	t.statuses = d.Statuses()
+	t.updateIngressRouteStatus()
 }
 
 // ingressClass returns the IngressClass
@@ -146,109 +216,148 @@ func (t *Translator) ingressClass() string {
 	return DEFAULT_INGRESS_CLASS
 }
 
-func (t *Translator) addIngress(i *v1beta1.Ingress) {
+// matchesIngressClass returns true if the supplied Ingress does not
+// specify an ingress class, or specifies Contour's ingress class.
+func (t *Translator) matchesIngressClass(i *v1beta1.Ingress) bool {
 	class, ok := i.Annotations["kubernetes.io/ingress.class"]
-	if ok && class != t.ingressClass() {
-		// if there is an ingress class set, but it is not set to configured
-		// or default ingress class, ignore this ingress.
-		// TODO(dfc) we should also skip creating any cluster backends,
-		// but this is hard to do at the moment because cds and rds are
-		// independent.
-		return
-	}
-
-	t.recomputeListeners(t.cache.ingresses, t.cache.secrets)
+	return !ok || class == t.ingressClass()
+}
 
-	// handle the special case of the default ingress first.
-	if i.Spec.Backend != nil {
-		// update t.vhosts cache
-		t.recomputevhost("*", t.cache.vhosts["*"])
+// updateIngressRouteStatus records the status of every IngressRoute,
+// as found by the last rebuild, and the load balancer status of every
+// root IngressRoute, with t.IngressRouteStatus, if configured. Only
+// the statuses which differ from those last written are recorded.
+func (t *Translator) updateIngressRouteStatus() {
+	if t.IngressRouteStatus == nil || !t.writesStatus() {
+		return
 	}
-
-	for _, rule := range i.Spec.Rules {
-		host := rule.Host
-		if host == "" {
-			// If the host is unspecified, the Ingress routes all traffic based on the specified IngressRuleValue.
-			host = "*"
+	written := make(map[metadata]ingressroutev1.Status, len(t.statuses))
+	for _, st := range t.statuses {
+		status := ingressroutev1.Status{
+			CurrentStatus: st.Status,
+			Description:   st.Description,
+			LoadBalancer:  st.Object.Status.LoadBalancer,
 		}
-		t.recomputevhost(host, t.cache.vhosts[host])
+		if t.publishesLoadBalancerStatus() && st.Object.Spec.VirtualHost.Fqdn != "" {
+			status.LoadBalancer = t.loadBalancerStatus()
+		}
+		md := metadata{name: st.Object.Name, namespace: st.Object.Namespace}
+		if last, ok := t.written[md]; ok && reflect.DeepEqual(last, status) {
+			written[md] = last
+			continue
+		}
+		if err := t.IngressRouteStatus.SetStatus(status, st.Object); err != nil {
+			// not recorded as written, so the next rebuild retries.
+			t.WithError(err).WithField("name", st.Object.Name).WithField("namespace", st.Object.Namespace).Error("failed to set IngressRoute status")
+			continue
+		}
+		written[md] = status
 	}
+	t.written = written
 }
 
-func (t *Translator) updateIngress(oldIng, newIng *v1beta1.Ingress) {
-	t.removeIngress(oldIng)
-	t.addIngress(newIng)
+// writesStatus returns true if t may write status, that is if
+// LeaderElection is not set or this Contour is leader, and
+// WaitForSync is not set or Synced has been called.
+func (t *Translator) writesStatus() bool {
+	if t.WaitForSync && !t.synced {
+		return false
+	}
+	return !t.LeaderElection || t.leading
 }
 
-func (t *Translator) removeIngress(i *v1beta1.Ingress) {
-	class, ok := i.Annotations["kubernetes.io/ingress.class"]
-	if ok && class != t.ingressClass() {
-		// if there is an ingress class set, but it is not set to configured
-		// or default ingress class, ignore this ingress.
-		// TODO(dfc) we should also skip creating any cluster backends,
-		// but this is hard to do at the moment because cds and rds are
-		// independent.
-		return
-	}
+// StartedLeading is called when this Contour is elected leader. It
+// writes any status which changed while another replica was leader.
+func (t *Translator) StartedLeading() {
+	t.leading = true
+	t.publishStatus()
+}
 
-	t.recomputeListeners(t.cache.ingresses, t.cache.secrets)
+// StoppedLeading is called when this Contour loses leadership. No
+// status is written until it is elected leader again.
+func (t *Translator) StoppedLeading() {
+	t.leading = false
+}
 
-	if i.Spec.Backend != nil {
-		t.recomputevhost("*", nil)
+// Synced is called once the initial list of every watched object has
+// been delivered to t. It builds the caches, and writes the status,
+// held back since then.
+func (t *Translator) Synced() {
+	t.synced = true
+	t.rebuild()
+
+	// streams wait for the first notification of their cache, which
+	// the first build does not send for a cache it leaves empty.
+	t.ClusterCache.Notify()
+	t.ListenerCache.Notify()
+	t.VirtualHostCache.Notify()
+	for _, i := range t.cache.ingresses {
+		t.publishIngressStatus(i)
 	}
+}
 
-	for _, rule := range i.Spec.Rules {
-		host := rule.Host
-		if host == "" {
-			// If the host is unspecified, the Ingress routes all traffic based on the specified IngressRuleValue.
-			host = "*"
-		}
-		t.recomputevhost(rule.Host, t.cache.vhosts[host])
+// publishStatus writes the status of every IngressRoute, whether or not
+// it changed, and the load balancer status of every Ingress and
+// IngressRoute.
+func (t *Translator) publishStatus() {
+	t.written = nil
+	t.updateIngressRouteStatus()
+	for _, i := range t.cache.ingresses {
+		t.publishIngressStatus(i)
 	}
 }
 
-func (t *Translator) addSecret(s *v1.Secret) {
-	t.recomputeTLSListener(t.cache.ingresses, t.cache.secrets)
+// loadBalancerStatus returns the load balancer status to be published
+// to Ingress and IngressRoute objects.
+func (t *Translator) loadBalancerStatus() v1.LoadBalancerStatus {
+	if t.StatusAddress == "" {
+		return t.lbStatus
+	}
+	if net.ParseIP(t.StatusAddress) != nil {
+		return v1.LoadBalancerStatus{
+			Ingress: []v1.LoadBalancerIngress{{IP: t.StatusAddress}},
+		}
+	}
+	return v1.LoadBalancerStatus{
+		Ingress: []v1.LoadBalancerIngress{{Hostname: t.StatusAddress}},
+	}
 }
 
-func (t *Translator) removeSecret(s *v1.Secret) {
-	t.recomputeTLSListener(t.cache.ingresses, t.cache.secrets)
+// publishesLoadBalancerStatus returns true if a source of load balancer
+// status, either the Envoy Service or a static address, is configured.
+func (t *Translator) publishesLoadBalancerStatus() bool {
+	return t.EnvoyServiceName != "" || t.StatusAddress != ""
 }
 
-func (t *Translator) addIngressRoute(r *ingressroutev1.IngressRoute) {
-
-	t.recomputeListenersIngressRoute(t.cache.routes, t.cache.secrets)
-
-	// notify watchers that the vhost cache has probably changed.
-	defer t.VirtualHostCache.Notify()
-
-	host := r.Spec.VirtualHost.Fqdn
-	if host == "" {
-		// If the host is unspecified, the Ingress routes all traffic based on the specified IngressRuleValue.
-		host = "*"
+// updateEnvoyService records the load balancer status of svc if it is the
+// Service exposing Envoy and, if it has changed, republishes it to every
+// Ingress. IngressRoutes receive it when their status is next written.
+func (t *Translator) updateEnvoyService(svc *v1.Service) {
+	if t.EnvoyServiceName == "" || svc.Name != t.EnvoyServiceName || svc.Namespace != t.EnvoyServiceNamespace {
+		return
 	}
-
-	t.recomputevhostIngressRoute(host, t.cache.vhostroutes[host])
-}
-
-func (t *Translator) removeIngressRoute(r *ingressroutev1.IngressRoute) {
-
-	defer t.VirtualHostCache.Notify()
-
-	t.recomputeListenersIngressRoute(t.cache.routes, t.cache.secrets)
-
-	host := r.Spec.VirtualHost.Fqdn
-	if host == "" {
-		// If the host is unspecified, the Ingress routes all traffic based on the specified IngressRuleValue.
-		host = "*"
+	if reflect.DeepEqual(t.lbStatus, svc.Status.LoadBalancer) {
+		return
+	}
+	t.lbStatus = *svc.Status.LoadBalancer.DeepCopy()
+	if t.StatusAddress != "" {
+		// the static address takes precedence, nothing to republish.
+		return
+	}
+	for _, i := range t.cache.ingresses {
+		t.publishIngressStatus(i)
 	}
-
-	t.recomputevhostIngressRoute(host, t.cache.vhostroutes[host])
 }
 
-func (t *Translator) updateIngressRoute(oldIng, newIng *ingressroutev1.IngressRoute) {
-	t.removeIngressRoute(oldIng)
-	t.addIngressRoute(newIng)
+// publishIngressStatus writes the current load balancer status to i
+// if i matches Contour's ingress class.
+func (t *Translator) publishIngressStatus(i *v1beta1.Ingress) {
+	if t.IngressStatus == nil || !t.writesStatus() || !t.publishesLoadBalancerStatus() || !t.matchesIngressClass(i) {
+		return
+	}
+	if err := t.IngressStatus.SetLoadBalancer(t.loadBalancerStatus(), i); err != nil {
+		t.WithError(err).WithField("name", i.Name).WithField("namespace", i.Namespace).Error("failed to set Ingress load balancer status")
+	}
 }
 
 // hashname takes a lenth l and a varargs of strings s and returns a string whose length
@@ -310,6 +419,16 @@ func apiconfigsource(clusters ...string) *core.ConfigSource {
 	}
 }
 
+// adsconfigsource returns a ConfigSource which retrieves
+// resources over the aggregated discovery service.
+func adsconfigsource() *core.ConfigSource {
+	return &core.ConfigSource{
+		ConfigSourceSpecifier: &core.ConfigSource_Ads{
+			Ads: &core.AggregatedConfigSource{},
+		},
+	}
+}
+
 // servicename returns a fixed name for this service and portname
 func servicename(meta metav1.ObjectMeta, portname string) string {
 	sn := []string{
@@ -23,6 +23,8 @@ import (
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
 	"github.com/gogo/protobuf/proto"
 	"github.com/sirupsen/logrus"
+
+	"github.com/heptio/contour/internal/dag"
 	"k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -134,6 +136,57 @@ func TestTranslatorAddService(t *testing.T) {
 	}
 }
 
+func TestTranslatorWaitForSync(t *testing.T) {
+	tr := &Translator{
+		FieldLogger: testLogger(t),
+		WaitForSync: true,
+	}
+	tr.OnAdd(service("default", "simple", v1.ServicePort{
+		Protocol:   "TCP",
+		Port:       80,
+		TargetPort: intstr.FromInt(6502),
+	}))
+	want := []proto.Message{}
+	got := contents(&tr.ClusterCache)
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("\nwant: %v\n got: %v", want, got)
+	}
+
+	// the caches are built once synced.
+	tr.Synced()
+	want = []proto.Message{
+		cluster("default/simple/80", "default/simple"),
+	}
+	got = contents(&tr.ClusterCache)
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("\nwant: %v\n got: %v", want, got)
+	}
+}
+
+func TestTranslatorSyncedEmpty(t *testing.T) {
+	tr := &Translator{
+		FieldLogger: testLogger(t),
+		WaitForSync: true,
+	}
+
+	// every cache is notified once synced, even if it is empty.
+	tr.Synced()
+	want := []proto.Message{}
+	got := contents(&tr.ClusterCache)
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("\nwant: %v\n got: %v", want, got)
+	}
+	for name, c := range map[string]*Cond{
+		"cluster":  &tr.ClusterCache.Cond,
+		"listener": &tr.ListenerCache.Cond,
+		"route":    &tr.VirtualHostCache.Cond,
+	} {
+		if got := c.Notifications(); got != 1 {
+			t.Errorf("%s: expected 1 notification, got %d", name, got)
+		}
+	}
+}
+
 func TestTranslatorUpdateService(t *testing.T) {
 	tests := map[string]struct {
 		oldObj *v1.Service
@@ -1151,6 +1204,63 @@ func TestTranslatorRemoveIngress(t *testing.T) {
 	}
 }
 
+func TestTranslatorIngressAndIngressRoute(t *testing.T) {
+	tr := &Translator{
+		FieldLogger: testLogger(t),
+	}
+	tr.OnAdd(&v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "simple",
+			Namespace: "default",
+		},
+		Spec: v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{{
+				Host:             "httpbin.org",
+				IngressRuleValue: ingressrulevalue(backend("peter", intstr.FromInt(80))),
+			}},
+		},
+	})
+	ir := ingressroute("default", "simple", "httpbin.org", irroute("/paul", "paul", 80))
+	tr.OnAdd(ir)
+
+	want := []proto.Message{
+		&route.VirtualHost{
+			Name:    "httpbin.org",
+			Domains: []string{"httpbin.org", "httpbin.org:80"},
+			Routes: []route.Route{{
+				Match:  prefixmatch("/paul"),
+				Action: weightedclusteraction("default/paul/80"),
+			}, {
+				Match:  prefixmatch("/"),
+				Action: clusteraction("default/peter/80"),
+			}},
+		},
+	}
+	got := contents(&tr.VirtualHostCache.HTTP)
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("(ingress_http): got: %v, want: %v", got, want)
+	}
+
+	// removing the ingressroute must not remove the ingress's
+	// routes, nor the listener they require.
+	tr.OnDelete(ir)
+	want = []proto.Message{
+		&route.VirtualHost{
+			Name:    "httpbin.org",
+			Domains: []string{"httpbin.org", "httpbin.org:80"},
+			Routes: []route.Route{{
+				Match:  prefixmatch("/"),
+				Action: clusteraction("default/peter/80"),
+			}},
+		},
+	}
+	got = contents(&tr.VirtualHostCache.HTTP)
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("(ingress_http): got: %v, want: %v", got, want)
+	}
+	assertCacheNotEmpty(t, &tr.ListenerCache)
+}
+
 func TestHashname(t *testing.T) {
 	tests := []struct {
 		name string
@@ -1293,6 +1403,15 @@ func (t *testWriter) Write(buf []byte) (int, error) {
 	return len(buf), nil
 }
 
+// buildDAG returns a DAG built from the supplied objects.
+func buildDAG(objs ...interface{}) *dag.DAG {
+	var b dag.Builder
+	for _, o := range objs {
+		b.Insert(o)
+	}
+	return b.Build()
+}
+
 func contents(v interface {
 	Values(func(string) bool) []proto.Message
 }) []proto.Message {
@@ -31,7 +31,6 @@ func TestTranslatorCacheOnAddIngress(t *testing.T) {
 	tests := map[string]struct {
 		i             v1beta1.Ingress
 		wantIngresses map[metadata]*v1beta1.Ingress
-		wantVhosts    map[string]map[metadata]*v1beta1.Ingress
 	}{
 		"add default ingress": {
 			i: v1beta1.Ingress{
@@ -54,19 +53,6 @@ func TestTranslatorCacheOnAddIngress(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*v1beta1.Ingress{
-				"*": map[metadata]*v1beta1.Ingress{
-					metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: v1beta1.IngressSpec{
-							Backend: backend("simple", intstr.FromInt(80)),
-						},
-					},
-				},
-			},
 		},
 		"add default rule ingress": {
 			i: v1beta1.Ingress{
@@ -93,21 +79,6 @@ func TestTranslatorCacheOnAddIngress(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*v1beta1.Ingress{
-				"*": map[metadata]*v1beta1.Ingress{
-					metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: v1beta1.IngressSpec{
-							Rules: []v1beta1.IngressRule{{
-								IngressRuleValue: ingressrulevalue(backend("simple", intstr.FromInt(80))),
-							}},
-						},
-					},
-				},
-			},
 		},
 		"add default and path default ingress": {
 			i: v1beta1.Ingress{
@@ -154,24 +125,6 @@ func TestTranslatorCacheOnAddIngress(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*v1beta1.Ingress{
-				"*": map[metadata]*v1beta1.Ingress{
-					metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: v1beta1.IngressSpec{Backend: backend("simple", intstr.FromInt(80)), Rules: []v1beta1.IngressRule{{IngressRuleValue: v1beta1.IngressRuleValue{
-							HTTP: &v1beta1.HTTPIngressRuleValue{Paths: []v1beta1.HTTPIngressPath{{
-								Path: "/hello", Backend: v1beta1.IngressBackend{
-									ServiceName: "hello", ServicePort: intstr.FromInt(80)},
-							}},
-							},
-						}}},
-						},
-					},
-				},
-			},
 		},
 		"add default and host ingress": {
 			i: v1beta1.Ingress{
@@ -220,56 +173,6 @@ func TestTranslatorCacheOnAddIngress(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*v1beta1.Ingress{
-				"*": map[metadata]*v1beta1.Ingress{
-					metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: v1beta1.IngressSpec{
-							Backend: backend("simple", intstr.FromInt(80)),
-							Rules: []v1beta1.IngressRule{{
-								Host: "hello.example.com",
-								IngressRuleValue: v1beta1.IngressRuleValue{
-									HTTP: &v1beta1.HTTPIngressRuleValue{
-										Paths: []v1beta1.HTTPIngressPath{{
-											Backend: v1beta1.IngressBackend{
-												ServiceName: "hello",
-												ServicePort: intstr.FromInt(80),
-											},
-										}},
-									},
-								},
-							}},
-						},
-					},
-				},
-				"hello.example.com": map[metadata]*v1beta1.Ingress{
-					metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: v1beta1.IngressSpec{
-							Backend: backend("simple", intstr.FromInt(80)),
-							Rules: []v1beta1.IngressRule{{
-								Host: "hello.example.com",
-								IngressRuleValue: v1beta1.IngressRuleValue{
-									HTTP: &v1beta1.HTTPIngressRuleValue{
-										Paths: []v1beta1.HTTPIngressPath{{
-											Backend: v1beta1.IngressBackend{
-												ServiceName: "hello",
-												ServicePort: intstr.FromInt(80),
-											},
-										}},
-									},
-								},
-							}},
-						},
-					},
-				},
-			},
 		},
 	}
 
@@ -280,9 +183,6 @@ func TestTranslatorCacheOnAddIngress(t *testing.T) {
 			if !reflect.DeepEqual(tc.wantIngresses, c.ingresses) {
 				t.Errorf("want:\n%v\n got:\n%v", tc.wantIngresses, c.ingresses)
 			}
-			if !reflect.DeepEqual(tc.wantVhosts, c.vhosts) {
-				t.Fatalf("want:\n%v\n got:\n%v", tc.wantVhosts, c.vhosts)
-			}
 		})
 	}
 }
@@ -527,6 +427,114 @@ func TestTranslatorAddIngressRoute(t *testing.T) {
 			},
 			ingress_https: []proto.Message{},
 		},
+		{
+			name: "delegated path",
+			setup: func(tr *Translator) {
+				tr.OnAdd(&ingressroutev1.IngressRoute{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "app",
+						Namespace: "teamx",
+					},
+					Spec: ingressroutev1.IngressRouteSpec{
+						Routes: []ingressroutev1.Route{{
+							Match: "/app",
+							Services: []ingressroutev1.Service{{
+								Name: "app",
+								Port: 8080,
+							}},
+						}, {
+							Match: "/other", // outside the delegated prefix, ignored
+							Services: []ingressroutev1.Service{{
+								Name: "other",
+								Port: 8080,
+							}},
+						}},
+					},
+				})
+			},
+			route: &ingressroutev1.IngressRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "root",
+					Namespace: "default",
+				},
+				Spec: ingressroutev1.IngressRouteSpec{
+					VirtualHost: ingressroutev1.VirtualHost{
+						Fqdn: "foo.bar",
+					},
+					Routes: []ingressroutev1.Route{{
+						Match: "/app",
+						Delegate: ingressroutev1.Delegate{
+							Name:      "app",
+							Namespace: "teamx",
+						},
+					}},
+				},
+			},
+			ingress_http: []proto.Message{
+				&route.VirtualHost{
+					Name:    "foo.bar",
+					Domains: []string{"foo.bar", "foo.bar:80"},
+					Routes: []route.Route{{
+						Match: prefixmatch("/app"),
+						Action: &route.Route_Route{
+							Route: &route.RouteAction{
+								ClusterSpecifier: &route.RouteAction_WeightedClusters{
+									WeightedClusters: &route.WeightedCluster{
+										Clusters: []*route.WeightedCluster_ClusterWeight{
+											{
+												Name: "teamx/app/8080",
+												Weight: &types.UInt32Value{
+													Value: uint32(100),
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					}},
+				},
+			},
+			ingress_https: []proto.Message{},
+		},
+		{
+			name: "delegation cycle",
+			setup: func(tr *Translator) {
+				tr.OnAdd(&ingressroutev1.IngressRoute{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "child",
+						Namespace: "default",
+					},
+					Spec: ingressroutev1.IngressRouteSpec{
+						Routes: []ingressroutev1.Route{{
+							Match: "/",
+							Delegate: ingressroutev1.Delegate{
+								Name: "root",
+							},
+						}},
+					},
+				})
+			},
+			route: &ingressroutev1.IngressRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "root",
+					Namespace: "default",
+				},
+				Spec: ingressroutev1.IngressRouteSpec{
+					VirtualHost: ingressroutev1.VirtualHost{
+						Fqdn: "foo.bar",
+					},
+					Routes: []ingressroutev1.Route{{
+						Match: "/",
+						Delegate: ingressroutev1.Delegate{
+							Name: "child",
+						},
+					}},
+				},
+			},
+			ingress_http:  []proto.Message{},
+			ingress_https: []proto.Message{},
+		},
 	}
 
 	log := testLogger(t)
@@ -559,7 +567,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 		c              translatorCache
 		oldObj, newObj v1beta1.Ingress
 		wantIngresses  map[metadata]*v1beta1.Ingress
-		wantVhosts     map[string]map[metadata]*v1beta1.Ingress
 	}{
 		"update default ingress": {
 			c: translatorCache{
@@ -574,19 +581,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 						},
 					},
 				},
-				vhosts: map[string]map[metadata]*v1beta1.Ingress{
-					"*": map[metadata]*v1beta1.Ingress{
-						metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "simple",
-								Namespace: "default",
-							},
-							Spec: v1beta1.IngressSpec{
-								Backend: backend("simple", intstr.FromInt(80)),
-							},
-						},
-					},
-				},
 			},
 			oldObj: v1beta1.Ingress{
 				ObjectMeta: metav1.ObjectMeta{
@@ -621,21 +615,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*v1beta1.Ingress{
-				"*": map[metadata]*v1beta1.Ingress{
-					metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: v1beta1.IngressSpec{
-							Rules: []v1beta1.IngressRule{{
-								IngressRuleValue: ingressrulevalue(backend("simple", intstr.FromInt(80))),
-							}},
-						},
-					},
-				},
-			},
 		},
 		"update default with host ingress": {
 			c: translatorCache{
@@ -652,21 +631,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 						},
 					},
 				},
-				vhosts: map[string]map[metadata]*v1beta1.Ingress{
-					"*": map[metadata]*v1beta1.Ingress{
-						metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "simple",
-								Namespace: "default",
-							},
-							Spec: v1beta1.IngressSpec{
-								Rules: []v1beta1.IngressRule{{
-									IngressRuleValue: ingressrulevalue(backend("simple", intstr.FromInt(80))),
-								}},
-							},
-						},
-					},
-				},
 			},
 			oldObj: v1beta1.Ingress{
 				ObjectMeta: metav1.ObjectMeta{
@@ -705,22 +669,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*v1beta1.Ingress{
-				"hello.example.com": map[metadata]*v1beta1.Ingress{
-					metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: v1beta1.IngressSpec{
-							Rules: []v1beta1.IngressRule{{
-								Host:             "hello.example.com",
-								IngressRuleValue: ingressrulevalue(backend("simple", intstr.FromInt(80))),
-							}},
-						},
-					},
-				},
-			},
 		},
 		"update host ingress to default": {
 			c: translatorCache{
@@ -738,22 +686,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 						},
 					},
 				},
-				vhosts: map[string]map[metadata]*v1beta1.Ingress{
-					"hello.example.com": map[metadata]*v1beta1.Ingress{
-						metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "simple",
-								Namespace: "default",
-							},
-							Spec: v1beta1.IngressSpec{
-								Rules: []v1beta1.IngressRule{{
-									Host:             "hello.example.com",
-									IngressRuleValue: ingressrulevalue(backend("simple", intstr.FromInt(80))),
-								}},
-							},
-						},
-					},
-				},
 			},
 			oldObj: v1beta1.Ingress{
 				ObjectMeta: metav1.ObjectMeta{
@@ -791,21 +723,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*v1beta1.Ingress{
-				"*": map[metadata]*v1beta1.Ingress{
-					metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: v1beta1.IngressSpec{
-							Rules: []v1beta1.IngressRule{{
-								IngressRuleValue: ingressrulevalue(backend("simple", intstr.FromInt(80))),
-							}},
-						},
-					},
-				},
-			},
 		},
 		"update rename host ingress": {
 			c: translatorCache{
@@ -823,22 +740,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 						},
 					},
 				},
-				vhosts: map[string]map[metadata]*v1beta1.Ingress{
-					"hello.example.com": map[metadata]*v1beta1.Ingress{
-						metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "simple",
-								Namespace: "default",
-							},
-							Spec: v1beta1.IngressSpec{
-								Rules: []v1beta1.IngressRule{{
-									Host:             "hello.example.com",
-									IngressRuleValue: ingressrulevalue(backend("simple", intstr.FromInt(80))),
-								}},
-							},
-						},
-					},
-				},
 			},
 			oldObj: v1beta1.Ingress{
 				ObjectMeta: metav1.ObjectMeta{
@@ -878,22 +779,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*v1beta1.Ingress{
-				"goodbye.example.com": map[metadata]*v1beta1.Ingress{
-					metadata{name: "simple", namespace: "default"}: &v1beta1.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: v1beta1.IngressSpec{
-							Rules: []v1beta1.IngressRule{{
-								Host:             "goodbye.example.com",
-								IngressRuleValue: ingressrulevalue(backend("simple", intstr.FromInt(80))),
-							}},
-						},
-					},
-				},
-			},
 		},
 		"move rename default ingress to named vhost without renaming object": { // issue 257
 			c: translatorCache{
@@ -911,22 +796,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 						},
 					},
 				},
-				vhosts: map[string]map[metadata]*v1beta1.Ingress{
-					"*": map[metadata]*v1beta1.Ingress{
-						metadata{name: "kuard-ing", namespace: "default"}: &v1beta1.Ingress{
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "kuard-ing",
-								Namespace: "default",
-							},
-							Spec: v1beta1.IngressSpec{
-								Backend: &v1beta1.IngressBackend{
-									ServiceName: "kuard",
-									ServicePort: intstr.FromInt(80),
-								},
-							},
-						},
-					},
-				},
 			},
 			oldObj: v1beta1.Ingress{
 				ObjectMeta: metav1.ObjectMeta{
@@ -986,32 +855,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*v1beta1.Ingress{
-				"kuard.db.gd-ms.com": map[metadata]*v1beta1.Ingress{
-					metadata{name: "kuard-ing", namespace: "default"}: &v1beta1.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "kuard-ing",
-							Namespace: "default",
-						},
-						Spec: v1beta1.IngressSpec{
-							Rules: []v1beta1.IngressRule{{
-								Host: "kuard.db.gd-ms.com",
-								IngressRuleValue: v1beta1.IngressRuleValue{
-									HTTP: &v1beta1.HTTPIngressRuleValue{
-										Paths: []v1beta1.HTTPIngressPath{{
-											Path: "/",
-											Backend: v1beta1.IngressBackend{
-												ServiceName: "kuard",
-												ServicePort: intstr.FromInt(80),
-											},
-										}},
-									},
-								},
-							}},
-						},
-					},
-				},
-			},
 		},
 	}
 
@@ -1021,9 +864,6 @@ func TestTranslatorCacheOnUpdateIngress(t *testing.T) {
 			if !reflect.DeepEqual(tc.wantIngresses, tc.c.ingresses) {
 				t.Errorf("ingresses want:\n%v\n got:\n%v", tc.wantIngresses, tc.c.ingresses)
 			}
-			if !reflect.DeepEqual(tc.wantVhosts, tc.c.vhosts) {
-				t.Fatalf("vhosts want:\n%+v\n got:\n%+v", tc.wantVhosts, tc.c.vhosts)
-			}
 		})
 	}
 }
@@ -1033,7 +873,6 @@ func TestTranslatorCacheOnUpdateIngressRoute(t *testing.T) {
 		c              translatorCache
 		oldObj, newObj ingressroutev1.IngressRoute
 		wantRoutes     map[metadata]*ingressroutev1.IngressRoute
-		wantVhosts     map[string]map[metadata]*ingressroutev1.IngressRoute
 	}{
 		"ingressroute update default ingress": {
 			c: translatorCache{
@@ -1057,28 +896,6 @@ func TestTranslatorCacheOnUpdateIngressRoute(t *testing.T) {
 						},
 					},
 				},
-				vhostroutes: map[string]map[metadata]*ingressroutev1.IngressRoute{
-					"*": map[metadata]*ingressroutev1.IngressRoute{
-						metadata{name: "simple", namespace: "default"}: &ingressroutev1.IngressRoute{
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "simple",
-								Namespace: "default",
-							},
-							Spec: ingressroutev1.IngressRouteSpec{
-								Routes: []ingressroutev1.Route{
-									{
-										Services: []ingressroutev1.Service{
-											{
-												Name: "simple",
-												Port: 80,
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-				},
 			},
 			oldObj: ingressroutev1.IngressRoute{
 				ObjectMeta: metav1.ObjectMeta{
@@ -1136,28 +953,6 @@ func TestTranslatorCacheOnUpdateIngressRoute(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*ingressroutev1.IngressRoute{
-				"*": map[metadata]*ingressroutev1.IngressRoute{
-					metadata{name: "simple", namespace: "default"}: &ingressroutev1.IngressRoute{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: ingressroutev1.IngressRouteSpec{
-							Routes: []ingressroutev1.Route{
-								{
-									Services: []ingressroutev1.Service{
-										{
-											Name: "simple",
-											Port: 80,
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
 		},
 		"ingressroute update default with host ingress": {
 			c: translatorCache{
@@ -1181,28 +976,6 @@ func TestTranslatorCacheOnUpdateIngressRoute(t *testing.T) {
 						},
 					},
 				},
-				vhostroutes: map[string]map[metadata]*ingressroutev1.IngressRoute{
-					"*": map[metadata]*ingressroutev1.IngressRoute{
-						metadata{name: "simple", namespace: "default"}: &ingressroutev1.IngressRoute{
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "simple",
-								Namespace: "default",
-							},
-							Spec: ingressroutev1.IngressRouteSpec{
-								Routes: []ingressroutev1.Route{
-									{
-										Services: []ingressroutev1.Service{
-											{
-												Name: "simple",
-												Port: 80,
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-				},
 			},
 			oldObj: ingressroutev1.IngressRoute{
 				ObjectMeta: metav1.ObjectMeta{
@@ -1266,31 +1039,6 @@ func TestTranslatorCacheOnUpdateIngressRoute(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*ingressroutev1.IngressRoute{
-				"hello.example.com": map[metadata]*ingressroutev1.IngressRoute{
-					metadata{name: "simple", namespace: "default"}: &ingressroutev1.IngressRoute{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: ingressroutev1.IngressRouteSpec{
-							VirtualHost: ingressroutev1.VirtualHost{
-								Fqdn: "hello.example.com",
-							},
-							Routes: []ingressroutev1.Route{
-								{
-									Services: []ingressroutev1.Service{
-										{
-											Name: "simple",
-											Port: 80,
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
 		},
 		"ingressroute update host ingress to default": {
 			c: translatorCache{
@@ -1317,31 +1065,6 @@ func TestTranslatorCacheOnUpdateIngressRoute(t *testing.T) {
 						},
 					},
 				},
-				vhostroutes: map[string]map[metadata]*ingressroutev1.IngressRoute{
-					"hello.example.com": map[metadata]*ingressroutev1.IngressRoute{
-						metadata{name: "simple", namespace: "default"}: &ingressroutev1.IngressRoute{
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "simple",
-								Namespace: "default",
-							},
-							Spec: ingressroutev1.IngressRouteSpec{
-								VirtualHost: ingressroutev1.VirtualHost{
-									Fqdn: "hello.example.com",
-								},
-								Routes: []ingressroutev1.Route{
-									{
-										Services: []ingressroutev1.Service{
-											{
-												Name: "simple",
-												Port: 80,
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-				},
 			},
 			oldObj: ingressroutev1.IngressRoute{
 				ObjectMeta: metav1.ObjectMeta{
@@ -1402,28 +1125,6 @@ func TestTranslatorCacheOnUpdateIngressRoute(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*ingressroutev1.IngressRoute{
-				"*": map[metadata]*ingressroutev1.IngressRoute{
-					metadata{name: "simple", namespace: "default"}: &ingressroutev1.IngressRoute{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: ingressroutev1.IngressRouteSpec{
-							Routes: []ingressroutev1.Route{
-								{
-									Services: []ingressroutev1.Service{
-										{
-											Name: "simple",
-											Port: 80,
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
 		},
 		"ingressroute update rename host ingress": {
 			c: translatorCache{
@@ -1450,31 +1151,6 @@ func TestTranslatorCacheOnUpdateIngressRoute(t *testing.T) {
 						},
 					},
 				},
-				vhostroutes: map[string]map[metadata]*ingressroutev1.IngressRoute{
-					"hello.example.com": map[metadata]*ingressroutev1.IngressRoute{
-						metadata{name: "simple", namespace: "default"}: &ingressroutev1.IngressRoute{
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "simple",
-								Namespace: "default",
-							},
-							Spec: ingressroutev1.IngressRouteSpec{
-								VirtualHost: ingressroutev1.VirtualHost{
-									Fqdn: "hello.example.com",
-								},
-								Routes: []ingressroutev1.Route{
-									{
-										Services: []ingressroutev1.Service{
-											{
-												Name: "simple",
-												Port: 80,
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-				},
 			},
 			oldObj: ingressroutev1.IngressRoute{
 				ObjectMeta: metav1.ObjectMeta{
@@ -1541,31 +1217,6 @@ func TestTranslatorCacheOnUpdateIngressRoute(t *testing.T) {
 					},
 				},
 			},
-			wantVhosts: map[string]map[metadata]*ingressroutev1.IngressRoute{
-				"goodbye.example.com": map[metadata]*ingressroutev1.IngressRoute{
-					metadata{name: "simple", namespace: "default"}: &ingressroutev1.IngressRoute{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "simple",
-							Namespace: "default",
-						},
-						Spec: ingressroutev1.IngressRouteSpec{
-							VirtualHost: ingressroutev1.VirtualHost{
-								Fqdn: "goodbye.example.com",
-							},
-							Routes: []ingressroutev1.Route{
-								{
-									Services: []ingressroutev1.Service{
-										{
-											Name: "simple",
-											Port: 80,
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
 		},
 	}
 
@@ -1575,9 +1226,6 @@ func TestTranslatorCacheOnUpdateIngressRoute(t *testing.T) {
 			if !reflect.DeepEqual(tc.wantRoutes, tc.c.routes) {
 				t.Errorf("routes want:\n%v\n got:\n%v", tc.wantRoutes, tc.c.routes)
 			}
-			if !reflect.DeepEqual(tc.wantVhosts, tc.c.vhostroutes) {
-				t.Fatalf("vhosts want:\n%+v\n got:\n%+v", tc.wantVhosts, tc.c.vhostroutes)
-			}
 		})
 	}
 }
@@ -1587,7 +1235,6 @@ func TestTranslatorCacheOnDeleteIngress(t *testing.T) {
 		c             translatorCache
 		i             v1beta1.Ingress
 		wantIngresses map[metadata]*v1beta1.Ingress
-		wantVhosts    map[string]map[metadata]*v1beta1.Ingress
 	}{
 		"remove default ingress": {
 			c: translatorCache{
@@ -1605,22 +1252,6 @@ func TestTranslatorCacheOnDeleteIngress(t *testing.T) {
 						},
 					},
 				},
-				vhosts: map[string]map[metadata]*v1beta1.Ingress{
-					"*": map[metadata]*v1beta1.Ingress{
-						metadata{name: "kuard-ing", namespace: "default"}: &v1beta1.Ingress{
-							ObjectMeta: metav1.ObjectMeta{
-								Name:      "kuard-ing",
-								Namespace: "default",
-							},
-							Spec: v1beta1.IngressSpec{
-								Backend: &v1beta1.IngressBackend{
-									ServiceName: "kuard",
-									ServicePort: intstr.FromInt(80),
-								},
-							},
-						},
-					},
-				},
 			},
 			i: v1beta1.Ingress{
 				ObjectMeta: metav1.ObjectMeta{
@@ -1636,7 +1267,6 @@ func TestTranslatorCacheOnDeleteIngress(t *testing.T) {
 			},
 
 			wantIngresses: map[metadata]*v1beta1.Ingress{},
-			wantVhosts:    map[string]map[metadata]*v1beta1.Ingress{},
 		},
 	}
 
@@ -1646,9 +1276,6 @@ func TestTranslatorCacheOnDeleteIngress(t *testing.T) {
 			if !reflect.DeepEqual(tc.wantIngresses, tc.c.ingresses) {
 				t.Errorf("want:\n%v\n got:\n%v", tc.wantIngresses, tc.c.ingresses)
 			}
-			if !reflect.DeepEqual(tc.wantVhosts, tc.c.vhosts) {
-				t.Fatalf("want:\n%v\n got:\n%v", tc.wantVhosts, tc.c.vhosts)
-			}
 		})
 	}
 }
@@ -15,13 +15,14 @@ package contour
 
 import (
 	"math"
+	"regexp"
 	"sort"
-	"strconv"
-	"strings"
 
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
 	"github.com/gogo/protobuf/types"
 	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
+	"github.com/heptio/contour/internal/dag"
 	"k8s.io/api/extensions/v1beta1"
 )
 
@@ -29,129 +30,146 @@ import (
 type VirtualHostCache struct {
 	HTTP  virtualHostCache
 	HTTPS virtualHostCache
+
+	// ClientValidated holds the secure virtual hosts which verify
+	// their clients. Each is served by its own route configuration,
+	// named by ClientValidatedRouteName, so that clients of other
+	// secure virtual hosts cannot reach it.
+	ClientValidated virtualHostCache
 	Cond
 }
 
-// recomputevhost recomputes the ingress_http (HTTP) and ingress_https (HTTPS) record
-// from the vhost from list of ingresses supplied.
-func (v *VirtualHostCache) recomputevhost(vhost string, ingresses map[metadata]*v1beta1.Ingress) {
-	// handle ingress_https (TLS) vhost routes first.
-	vv := virtualhost(vhost, "443")
-	for _, ing := range ingresses {
-		if !validTLSSpecforVhost(vhost, ing) {
-			continue
-		}
-		wr := websocketRoutes(ing)
-		for _, rule := range ing.Spec.Rules {
-			if rule.Host != "" && rule.Host != vhost {
-				continue
+// recompute recomputes the ingress_http (HTTP) and ingress_https (HTTPS)
+// records, and the records of the secure virtual hosts which verify their
+// clients, from the virtual hosts in d and notifies watchers of any change.
+// Secure virtual hosts without routes, or whose clients cannot be
+// verified, are not added.
+func (v *VirtualHostCache) recompute(d *dag.DAG) {
+	var http, https, validated []*route.VirtualHost
+	d.Visit(func(vx dag.Vertex) {
+		switch vh := vx.(type) {
+		case *dag.VirtualHost:
+			http = append(http, routevirtualhost(vh, "80"))
+		case *dag.SecureVirtualHost:
+			if cv := vh.ClientValidation; cv != nil && cv.CACertificate == nil {
+				// the CA certificate is missing, clients cannot be verified.
+				return
 			}
-			if rule.IngressRuleValue.HTTP == nil {
-				// TODO(dfc) plumb a logger in here so we can log this error.
-				continue
+			if len(vh.Routes()) == 0 {
+				return
 			}
-
-			for _, p := range rule.IngressRuleValue.HTTP.Paths {
-				vv.Routes = append(vv.Routes, route.Route{
-					Match:  pathToRouteMatch(p),
-					Action: action(ing, &p.Backend, wr[p.Path]),
-				})
+			if vh.ClientValidation != nil {
+				validated = append(validated, routevirtualhost(&vh.VirtualHost, "443"))
+				return
 			}
+			https = append(https, routevirtualhost(&vh.VirtualHost, "443"))
 		}
+	})
+	changed := v.HTTP.Replace(http...)
+	changed = v.HTTPS.Replace(https...) || changed
+	changed = v.ClientValidated.Replace(validated...) || changed
+	if changed {
+		v.Notify()
 	}
-	if len(vv.Routes) > 0 {
-		sort.Stable(sort.Reverse(longestRouteFirst(vv.Routes)))
-		v.HTTPS.Add(vv)
-	} else {
-		v.HTTPS.Remove(vv.Name)
-	}
+}
 
-	// now handle ingress_http (non tls) routes.
-	vv = virtualhost(vhost, "80")
-	for _, i := range ingresses {
-		if !httpAllowed(i) {
-			// skip this vhosts ingress_http route.
-			continue
-		}
-		wr := websocketRoutes(i)
-		requireTLS := tlsRequired(i)
-		if i.Spec.Backend != nil && len(ingresses) == 1 {
-			r := route.Route{
-				Match:  prefixmatch("/"),
-				Action: action(i, i.Spec.Backend, wr["/"]),
-			}
+// ClientValidatedRouteName returns the name of the route configuration
+// which serves only the secure virtual host, which verifies its clients,
+// whose route.VirtualHost is named name.
+func ClientValidatedRouteName(name string) string {
+	return ENVOY_HTTPS_LISTENER + "/" + name
+}
 
-			if requireTLS {
-				r.Action = &route.Route_Redirect{
-					Redirect: &route.RedirectAction{
-						HttpsRedirect: true,
-					},
-				}
-			}
-			vv.Routes = []route.Route{r}
-			continue
+// routevirtualhost returns the route.VirtualHost for vh served on hostport.
+func routevirtualhost(vh *dag.VirtualHost, hostport string) *route.VirtualHost {
+	rv := virtualhost(vh.Host, hostport)
+	rv.Domains = appendAliases(rv.Domains, vh.Aliases, hostport)
+	if p := vh.HeadersPolicy; p != nil {
+		rv.RequestHeadersToAdd = headervalueoptions(p.RequestHeadersToAdd)
+		rv.ResponseHeadersToAdd = headervalueoptions(p.ResponseHeadersToAdd)
+		rv.ResponseHeadersToRemove = p.ResponseHeadersToRemove
+	}
+	for _, r := range vh.Routes() {
+		rr := route.Route{
+			Match: routematch(r),
 		}
-		for _, rule := range i.Spec.Rules {
-			if rule.Host != "" && rule.Host != vhost {
-				continue
+		switch obj := r.Object.(type) {
+		case *v1beta1.Ingress:
+			rr.Action = action(obj, r.Backends[0].Service, r.Websocket)
+		case *ingressroutev1.IngressRoute:
+			if r.Redirect != nil {
+				rr.Action = redirectaction(r.Redirect)
+			} else {
+				rr.Action = actionroute(r.Backends)
 			}
-			if rule.IngressRuleValue.HTTP == nil {
-				// TODO(dfc) plumb a logger in here so we can log this error.
-				continue
-			}
-			for _, p := range rule.IngressRuleValue.HTTP.Paths {
-				r := route.Route{
-					Match:  pathToRouteMatch(p),
-					Action: action(i, &p.Backend, wr[p.Path]),
-				}
-				if requireTLS {
-					r.Action = &route.Route_Redirect{
-						Redirect: &route.RedirectAction{
-							HttpsRedirect: true,
-						},
-					}
-				}
-				vv.Routes = append(vv.Routes, r)
+		}
+		if a, ok := rr.Action.(*route.Route_Route); ok {
+			a.Route.PrefixRewrite = r.PrefixRewrite
+		}
+		if r.HTTPSUpgrade {
+			rr.Action = &route.Route_Redirect{
+				Redirect: &route.RedirectAction{
+					HttpsRedirect: true,
+				},
 			}
 		}
+		rv.Routes = append(rv.Routes, rr)
 	}
-	if len(vv.Routes) > 0 {
-		sort.Stable(sort.Reverse(longestRouteFirst(vv.Routes)))
-		v.HTTP.Add(vv)
-	} else {
-		v.HTTP.Remove(vv.Name)
-	}
+	sort.Stable(sort.Reverse(longestRouteFirst(rv.Routes)))
+	return rv
 }
 
-// recomputevhostIngressRoute recomputes the ingress_http (HTTP) and ingress_https (HTTPS) record
-// from the vhost from list of ingresses supplied.
-func (v *VirtualHostCache) recomputevhostIngressRoute(vhost string, routes map[metadata]*ingressroutev1.IngressRoute) {
-	// now handle ingress_http (non tls) routes.
-	vv := virtualhost(vhost, "80")
-	for _, i := range routes {
-		for _, j := range i.Spec.Routes {
-
-			// TODO(sas): Handle case of no default path (e.g. "/")
-
-			vv.Routes = append(vv.Routes, route.Route{
-				Match:  prefixmatch(j.Match),
-				Action: actionroute(i.ObjectMeta.Namespace, j.Services),
-			})
-		}
+// routematch returns the RouteMatch for r.
+func routematch(r *dag.Route) route.RouteMatch {
+	var rm route.RouteMatch
+	switch {
+	case r.Regex != "":
+		rm = regexmatch(r.Regex)
+	case r.Exact != "":
+		rm = pathmatch(r.Exact)
+	default:
+		rm = prefixmatch(r.Prefix)
+	}
+	for _, h := range r.Headers {
+		rm.Headers = append(rm.Headers, headermatcher(h))
 	}
+	return rm
+}
 
-	if len(vv.Routes) > 0 {
-		sort.Stable(sort.Reverse(longestRouteFirst(vv.Routes)))
-		v.HTTP.Add(vv)
-	} else {
-		v.HTTP.Remove(vv.Name)
+// headermatcher returns the HeaderMatcher for h. Envoy can only
+// match a header's value, so an inverted condition is matched by a
+// regex which matches any value except those matched by h. As such
+// an inverted condition does not match a request without the header.
+// The regex is a negative lookahead, which Envoy's ECMAScript grammar
+// supports, around h's regex, which the DAG has validated to be free
+// of lookaround and backreferences.
+func headermatcher(h dag.HeaderCondition) *route.HeaderMatcher {
+	hm := &route.HeaderMatcher{Name: h.Name}
+	switch h.MatchType {
+	case dag.HeaderMatchTypeExact:
+		if !h.Invert {
+			hm.Value = h.Value
+			return hm
+		}
+		hm.Value = "^(?!" + regexp.QuoteMeta(h.Value) + "$).*"
+	case dag.HeaderMatchTypeRegex:
+		hm.Value = h.Value
+		if h.Invert {
+			hm.Value = "^(?!(?:" + h.Value + ")$).*"
+		}
+	default:
+		// a header matcher without a value matches
+		// the presence of the header.
+		return hm
 	}
+	hm.Regex = &types.BoolValue{Value: true}
+	return hm
 }
 
 // action computes the cluster route action, a *route.Route_route for the
-// supplied ingress and backend.
-func action(i *v1beta1.Ingress, be *v1beta1.IngressBackend, useWebsocket *types.BoolValue) *route.Route_Route {
-	name := ingressBackendToClusterName(i.ObjectMeta.Namespace, be.ServiceName, be.ServicePort.String())
+// supplied ingress and backend service.
+func action(i *v1beta1.Ingress, s *dag.Service, websocket bool) *route.Route_Route {
+	name := ingressBackendToClusterName(s.Namespace, s.Name, s.Port)
 	ca := route.Route_Route{
 		Route: &route.RouteAction{
 			ClusterSpecifier: &route.RouteAction_Cluster{
@@ -173,14 +191,38 @@ func action(i *v1beta1.Ingress, be *v1beta1.IngressBackend, useWebsocket *types.
 		}
 	}
 
-	ca.Route.UseWebsocket = useWebsocket
+	if websocket {
+		ca.Route.UseWebsocket = &types.BoolValue{Value: true}
+	}
 
 	return &ca
 }
 
+// redirectaction returns the redirect route action for rd.
+func redirectaction(rd *dag.Redirect) *route.Route_Redirect {
+	ra := route.RedirectAction{
+		HostRedirect:  rd.Host,
+		HttpsRedirect: rd.HTTPS,
+	}
+	if rd.Path != "" {
+		ra.PathRewriteSpecifier = &route.RedirectAction_PathRedirect{PathRedirect: rd.Path}
+	}
+	switch rd.StatusCode {
+	case 302:
+		ra.ResponseCode = route.RedirectAction_FOUND
+	case 307:
+		ra.ResponseCode = route.RedirectAction_TEMPORARY_REDIRECT
+	case 308:
+		ra.ResponseCode = route.RedirectAction_PERMANENT_REDIRECT
+	default:
+		ra.ResponseCode = route.RedirectAction_MOVED_PERMANENTLY
+	}
+	return &route.Route_Redirect{Redirect: &ra}
+}
+
 // actionroute computes the cluster route action, a *v2.Route_route for the
-// supplied ingress and backend
-func actionroute(namespace string, be []ingressroutev1.Service) *route.Route_Route {
+// supplied ingressroute backends.
+func actionroute(be []dag.Backend) *route.Route_Route {
 
 	totalWeight := 100
 	totalUpstreams := len(be)
@@ -190,7 +232,7 @@ func actionroute(namespace string, be []ingressroutev1.Service) *route.Route_Rou
 	// Loop over all the upstreams and add to slice
 	for _, i := range be {
 
-		name := ingressBackendToClusterName(namespace, i.Name, strconv.Itoa(i.Port))
+		name := ingressBackendToClusterName(i.Namespace, i.Name, i.Port)
 
 		// Create the empty upstream
 		upstream := route.WeightedCluster_ClusterWeight{
@@ -227,66 +269,52 @@ func actionroute(namespace string, be []ingressroutev1.Service) *route.Route_Rou
 	return &ca
 }
 
-// validTLSSpecForVhost returns if this ingress object
-// contains a TLS spec that matches the vhost supplied,
-func validTLSSpecforVhost(vhost string, i *v1beta1.Ingress) bool {
-	for _, tls := range i.Spec.TLS {
-		if tls.SecretName == "" {
-			// not a valid TLS spec without a secret for the cert.
-			continue
-		}
-
-		for _, h := range tls.Hosts {
-			if h == vhost {
-				return true
-			}
-		}
-	}
-	return false
-}
-
+// longestRouteFirst orders routes from least to most specific, it is
+// sorted in reverse so that Envoy, which uses the first route matching
+// a request, uses the most specific. Prefix matches are less specific
+// than regex matches, which are less specific than exact matches.
+// Prefix and exact matches are ordered by path, so that a longer path
+// sorts after any of its prefixes. Regex matches are kept in the order
+// they were defined. Routes with the same path are ordered by their
+// number of header conditions.
 type longestRouteFirst []route.Route
 
 func (l longestRouteFirst) Len() int      { return len(l) }
 func (l longestRouteFirst) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
 func (l longestRouteFirst) Less(i, j int) bool {
-	a, ok := l[i].Match.PathSpecifier.(*route.RouteMatch_Prefix)
-	if !ok {
-		// ignore non prefix matches
-		return false
+	a, b := l[i].Match, l[j].Match
+	if ka, kb := matchKind(a), matchKind(b); ka != kb {
+		return ka < kb
 	}
-
-	b, ok := l[j].Match.PathSpecifier.(*route.RouteMatch_Prefix)
-	if !ok {
-		// ignore non prefix matches
-		return false
+	if pa, pb := matchPath(a), matchPath(b); pa != pb {
+		return pa < pb
 	}
-
-	return a.Prefix < b.Prefix
+	// routes with more header conditions are more specific.
+	return len(a.Headers) < len(b.Headers)
 }
 
-// pathToRoute converts a HTTPIngressPath to a partial route.RouteMatch.
-func pathToRouteMatch(p v1beta1.HTTPIngressPath) route.RouteMatch {
-	if p.Path == "" {
-		// If the Path is empty, the k8s spec says
-		// "If unspecified, the path defaults to a catch all sending
-		// traffic to the backend."
-		// We map this it a catch all prefix route.
-		return prefixmatch("/") // match all
+// matchKind ranks the path specifier of m by specificity.
+func matchKind(m route.RouteMatch) int {
+	switch m.PathSpecifier.(type) {
+	case *route.RouteMatch_Path:
+		return 2
+	case *route.RouteMatch_Regex:
+		return 1
+	default:
+		return 0
 	}
-	// TODO(dfc) handle the case where p.Path does not start with "/"
-	if strings.IndexAny(p.Path, `[(*\`) == -1 {
-		// Envoy requires that regex matches match completely, wheres the
-		// HTTPIngressPath.Path regex only requires a partial match. eg,
-		// "/foo" matches "/" according to k8s rules, but does not match
-		// according to Envoy.
-		// To deal with this we handle the simple case, a Path without regex
-		// characters as a Envoy prefix route.
-		return prefixmatch(p.Path)
+}
+
+// matchPath returns the path matched by m, or "" for regex matches.
+func matchPath(m route.RouteMatch) string {
+	switch p := m.PathSpecifier.(type) {
+	case *route.RouteMatch_Path:
+		return p.Path
+	case *route.RouteMatch_Prefix:
+		return p.Prefix
+	default:
+		return ""
 	}
-	// At this point the path is a regex, which we hope is the same between k8s
-	// IEEE 1003.1 POSIX regex, and Envoys Javascript regex.
-	return regexmatch(p.Path)
 }
 
 // ingressBackendToClusterName renders a cluster name from an namespace, servicename, & service port
@@ -303,6 +331,15 @@ func prefixmatch(prefix string) route.RouteMatch {
 	}
 }
 
+// pathmatch returns a RouteMatch for the supplied path.
+func pathmatch(path string) route.RouteMatch {
+	return route.RouteMatch{
+		PathSpecifier: &route.RouteMatch_Path{
+			Path: path,
+		},
+	}
+}
+
 // regexmatch returns a RouteMatch for the supplied regex.
 func regexmatch(regex string) route.RouteMatch {
 	return route.RouteMatch{
@@ -312,6 +349,29 @@ func regexmatch(regex string) route.RouteMatch {
 	}
 }
 
+// headervalueoptions returns a HeaderValueOption, which appends
+// to any existing values, for each of values.
+func headervalueoptions(values []dag.HeaderValue) []*core.HeaderValueOption {
+	var options []*core.HeaderValueOption
+	for _, v := range values {
+		options = append(options, &core.HeaderValueOption{
+			Header: &core.HeaderValue{
+				Key:   v.Name,
+				Value: v.Value,
+			},
+		})
+	}
+	return options
+}
+
+// appendAliases appends each alias, with and without hostport, to domains.
+func appendAliases(domains, aliases []string, hostport string) []string {
+	for _, a := range aliases {
+		domains = append(domains, a, a+":"+hostport)
+	}
+	return domains
+}
+
 func virtualhost(hostname, hostport string) *route.VirtualHost {
 	var domains []string
 	if hostname != "*" {
@@ -19,17 +19,20 @@ import (
 	"testing"
 	"time"
 
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
 	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
 	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
+	"github.com/heptio/contour/internal/dag"
 	"github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
-func TestVirtualHostCacheRecomputevhost(t *testing.T) {
+func TestVirtualHostCacheRecompute(t *testing.T) {
 	im := func(ings []*v1beta1.Ingress) map[metadata]*v1beta1.Ingress {
 		m := make(map[metadata]*v1beta1.Ingress)
 		for _, i := range ings {
@@ -582,7 +585,7 @@ func TestVirtualHostCacheRecomputevhost(t *testing.T) {
 					Name:      "echo",
 					Namespace: "default",
 					Annotations: map[string]string{
-						annotationWebsocketRoutes: "/ws1",
+						"contour.heptio.com/websocket-routes": "/ws1",
 					},
 				},
 				Spec: v1beta1.IngressSpec{
@@ -618,7 +621,7 @@ func TestVirtualHostCacheRecomputevhost(t *testing.T) {
 					Name:      "echo",
 					Namespace: "default",
 					Annotations: map[string]string{
-						annotationWebsocketRoutes: "/ws1",
+						"contour.heptio.com/websocket-routes": "/ws1",
 					},
 				},
 				Spec: v1beta1.IngressSpec{
@@ -670,77 +673,21 @@ func TestVirtualHostCacheRecomputevhost(t *testing.T) {
 			tr := &Translator{
 				FieldLogger: log,
 			}
-			tr.recomputevhost(tc.vhost, tc.ingresses)
-			got := contents(&tr.VirtualHostCache.HTTP)
+			var objs []interface{}
+			for _, i := range tc.ingresses {
+				objs = append(objs, i)
+			}
+			tr.VirtualHostCache.recompute(buildDAG(objs...))
+			got := vhostcontents(&tr.VirtualHostCache.HTTP, tc.vhost)
 			sort.Stable(virtualHostsByName(got))
 			if !reflect.DeepEqual(tc.ingress_http, got) {
-				t.Fatalf("recomputevhost(%v):\n (ingress_http) want:\n%+v\n got:\n%+v", tc.vhost, tc.ingress_http, got)
+				t.Fatalf("recompute(%v):\n (ingress_http) want:\n%+v\n got:\n%+v", tc.vhost, tc.ingress_http, got)
 			}
 
-			got = contents(&tr.VirtualHostCache.HTTPS)
+			got = vhostcontents(&tr.VirtualHostCache.HTTPS, tc.vhost)
 			sort.Stable(virtualHostsByName(got))
 			if !reflect.DeepEqual(tc.ingress_https, got) {
-				t.Fatalf("recomputevhost(%v):\n (ingress_https) want:\n%#v\ngot:\n%#v", tc.vhost, tc.ingress_https, got)
-			}
-		})
-	}
-}
-
-func TestValidTLSSpecforVhost(t *testing.T) {
-	tests := map[string]struct {
-		vhost string
-		ing   v1beta1.Ingress
-		want  bool
-	}{
-		"default vhost": {
-			vhost: "*",
-			ing: v1beta1.Ingress{
-				Spec: v1beta1.IngressSpec{},
-			},
-			want: false,
-		},
-		"tls enabled": {
-			vhost: "httpbin.davecheney.com",
-			ing: v1beta1.Ingress{
-				Spec: v1beta1.IngressSpec{
-					TLS: []v1beta1.IngressTLS{{
-						Hosts:      []string{"httpbin.davecheney.com"},
-						SecretName: "httpbin",
-					}},
-				},
-			},
-			want: true,
-		},
-		"wrong hostname": {
-			vhost: "httpbin.davecheney.com",
-			ing: v1beta1.Ingress{
-				Spec: v1beta1.IngressSpec{
-					TLS: []v1beta1.IngressTLS{{
-						Hosts:      []string{"www.davecheney.com"},
-						SecretName: "dubdubdub",
-					}},
-				},
-			},
-			want: false,
-		},
-		"missing secret spec": {
-			vhost: "httpbin.davecheney.com",
-			ing: v1beta1.Ingress{
-				Spec: v1beta1.IngressSpec{
-					TLS: []v1beta1.IngressTLS{{
-						Hosts: []string{"httpbin.davecheney.com"},
-					}},
-				},
-			},
-			want: false,
-		},
-	}
-
-	for name, tc := range tests {
-		t.Run(name, func(t *testing.T) {
-			got := validTLSSpecforVhost(tc.vhost, &tc.ing)
-			if got != tc.want {
-				t.Fatal("got", got, "want", tc.want)
+				t.Fatalf("recompute(%v):\n (ingress_https) want:\n%#v\ngot:\n%#v", tc.vhost, tc.ingress_https, got)
 			}
 		})
 	}
@@ -776,6 +723,23 @@ func websocketaction(name string) *route.Route_Route {
 	return c
 }
 
+// weightedclusteraction returns a weighted cluster action which forwards
+// all requests to the supplied cluster.
+func weightedclusteraction(name string) *route.Route_Route {
+	return &route.Route_Route{
+		Route: &route.RouteAction{
+			ClusterSpecifier: &route.RouteAction_WeightedClusters{
+				WeightedClusters: &route.WeightedCluster{
+					Clusters: []*route.WeightedCluster_ClusterWeight{{
+						Name:   name,
+						Weight: &types.UInt32Value{Value: 100},
+					}},
+				},
+			},
+		},
+	}
+}
+
 // redirecthttps returns a 301 redirect to the HTTPS scheme.
 func redirecthttps() *route.Route_Redirect {
 	return &route.Route_Redirect{
@@ -785,7 +749,7 @@ func redirecthttps() *route.Route_Redirect {
 	}
 }
 
-func TestVirtualHostCacheRecomputevhostIngressRoute(t *testing.T) {
+func TestVirtualHostCacheRecomputeIngressRoute(t *testing.T) {
 	im := func(routes []*ingressroutev1.IngressRoute) map[metadata]*ingressroutev1.IngressRoute {
 		m := make(map[metadata]*ingressroutev1.IngressRoute)
 		for _, i := range routes {
@@ -1027,7 +991,7 @@ func TestVirtualHostCacheRecomputevhostIngressRoute(t *testing.T) {
 			},
 			ingress_https: []proto.Message{},
 		},
-		"ingress route second ingress object extends an existing vhost": {
+		"ingress route second root with the same fqdn": {
 			vhost: "httpbin.org",
 			routes: im([]*ingressroutev1.IngressRoute{
 				{
@@ -1073,49 +1037,8 @@ func TestVirtualHostCacheRecomputevhostIngressRoute(t *testing.T) {
 						},
 					},
 				}}),
-			ingress_http: []proto.Message{
-				&route.VirtualHost{
-					Name:    "httpbin.org",
-					Domains: []string{"httpbin.org", "httpbin.org:80"},
-					Routes: []route.Route{{
-						Match: prefixmatch("/admin"),
-						Action: &route.Route_Route{
-							Route: &route.RouteAction{
-								ClusterSpecifier: &route.RouteAction_WeightedClusters{
-									WeightedClusters: &route.WeightedCluster{
-										Clusters: []*route.WeightedCluster_ClusterWeight{
-											{
-												Name: "kube-system/admin/80",
-												Weight: &types.UInt32Value{
-													Value: uint32(100),
-												},
-											},
-										},
-									},
-								},
-							},
-						},
-					}, {
-						Match: prefixmatch("/"),
-						Action: &route.Route_Route{
-							Route: &route.RouteAction{
-								ClusterSpecifier: &route.RouteAction_WeightedClusters{
-									WeightedClusters: &route.WeightedCluster{
-										Clusters: []*route.WeightedCluster_ClusterWeight{
-											{
-												Name: "default/default/80",
-												Weight: &types.UInt32Value{
-													Value: uint32(100),
-												},
-											},
-										},
-									},
-								},
-							},
-						},
-					}},
-				},
-			},
+			// roots which share a fqdn are invalid, neither is served.
+			ingress_http:  []proto.Message{},
 			ingress_https: []proto.Message{},
 		},
 		"ingress route IngressRuleValue without host should become the default vhost": { // heptio/contour#101
@@ -1425,6 +1348,32 @@ func TestVirtualHostCacheRecomputevhostIngressRoute(t *testing.T) {
 			},
 			ingress_https: []proto.Message{},
 		},
+		"ingress route tls vhost with aliases": {
+			vhost: "httpbin.org",
+			routes: im([]*ingressroutev1.IngressRoute{
+				tlsingressroute("default", "httpbin", "httpbin.org", "secret", "www.httpbin.org"),
+			}),
+			ingress_http: []proto.Message{
+				&route.VirtualHost{
+					Name:    "httpbin.org",
+					Domains: []string{"httpbin.org", "httpbin.org:80", "www.httpbin.org", "www.httpbin.org:80"},
+					Routes: []route.Route{{
+						Match:  prefixmatch("/"),
+						Action: weightedclusteraction("default/backend/80"),
+					}},
+				},
+			},
+			ingress_https: []proto.Message{
+				&route.VirtualHost{
+					Name:    "httpbin.org",
+					Domains: []string{"httpbin.org", "httpbin.org:443", "www.httpbin.org", "www.httpbin.org:443"},
+					Routes: []route.Route{{
+						Match:  prefixmatch("/"),
+						Action: weightedclusteraction("default/backend/80"),
+					}},
+				},
+			},
+		},
 	}
 	log := logrus.New()
 	log.Out = &testWriter{t}
@@ -1433,22 +1382,295 @@ func TestVirtualHostCacheRecomputevhostIngressRoute(t *testing.T) {
 			tr := &Translator{
 				FieldLogger: log,
 			}
-			tr.recomputevhostIngressRoute(tc.vhost, tc.routes)
-			got := contents(&tr.VirtualHostCache.HTTP)
+			var objs []interface{}
+			for _, r := range tc.routes {
+				objs = append(objs, r)
+			}
+			tr.VirtualHostCache.recompute(buildDAG(objs...))
+			got := vhostcontents(&tr.VirtualHostCache.HTTP, tc.vhost)
 			sort.Stable(virtualHostsByName(got))
 			if !reflect.DeepEqual(tc.ingress_http, got) {
-				t.Fatalf("recomputevhost(%v):\n (ingress_http) want:\n%+v\n got:\n%+v", tc.vhost, tc.ingress_http, got)
+				t.Fatalf("recompute(%v):\n (ingress_http) want:\n%+v\n got:\n%+v", tc.vhost, tc.ingress_http, got)
 			}
 
-			got = contents(&tr.VirtualHostCache.HTTPS)
+			got = vhostcontents(&tr.VirtualHostCache.HTTPS, tc.vhost)
 			sort.Stable(virtualHostsByName(got))
 			if !reflect.DeepEqual(tc.ingress_https, got) {
-				t.Fatalf("recomputevhost(%v):\n (ingress_https) want:\n%#v\ngot:\n%#v", tc.vhost, tc.ingress_https, got)
+				t.Fatalf("recompute(%v):\n (ingress_https) want:\n%#v\ngot:\n%#v", tc.vhost, tc.ingress_https, got)
+			}
+		})
+	}
+}
+
+func TestVirtualHostCacheRecomputeClientValidation(t *testing.T) {
+	ir1 := tlsingressroute("default", "public", "example.com", "secret")
+	ir2 := tlsingressroute("default", "private", "private.example.com", "secret")
+	ir2.Spec.VirtualHost.TLS.ClientValidation = &ingressroutev1.ClientValidation{
+		CACertificate: "ca",
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Data: secretdata("certificate", "key"),
+	}
+	ca := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ca",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			dag.CACertificateKey: []byte("ca"),
+		},
+	}
+
+	var tr Translator
+	tr.VirtualHostCache.recompute(buildDAG(ir1, ir2, secret, ca))
+
+	public := &route.VirtualHost{
+		Name:    "example.com",
+		Domains: []string{"example.com", "example.com:443"},
+		Routes: []route.Route{{
+			Match:  prefixmatch("/"),
+			Action: weightedclusteraction("default/backend/80"),
+		}},
+	}
+	private := &route.VirtualHost{
+		Name:    "private.example.com",
+		Domains: []string{"private.example.com", "private.example.com:443"},
+		Routes: []route.Route{{
+			Match:  prefixmatch("/"),
+			Action: weightedclusteraction("default/backend/80"),
+		}},
+	}
+	// private is served neither over HTTP, nor by ingress_https.
+	want := []proto.Message{
+		&route.VirtualHost{
+			Name:    "example.com",
+			Domains: []string{"example.com", "example.com:80"},
+			Routes: []route.Route{{
+				Match:  prefixmatch("/"),
+				Action: weightedclusteraction("default/backend/80"),
+			}},
+		},
+	}
+	if got := contents(&tr.VirtualHostCache.HTTP); !reflect.DeepEqual(want, got) {
+		t.Fatalf("ingress_http: want:\n%+v\ngot:\n%+v", want, got)
+	}
+	want = []proto.Message{public}
+	if got := contents(&tr.VirtualHostCache.HTTPS); !reflect.DeepEqual(want, got) {
+		t.Fatalf("ingress_https: want:\n%+v\ngot:\n%+v", want, got)
+	}
+	want = []proto.Message{private}
+	if got := contents(&tr.VirtualHostCache.ClientValidated); !reflect.DeepEqual(want, got) {
+		t.Fatalf("client validated: want:\n%+v\ngot:\n%+v", want, got)
+	}
+}
+
+func TestRouteMatchHeaders(t *testing.T) {
+	tests := map[string]struct {
+		header dag.HeaderCondition
+		want   *route.HeaderMatcher
+	}{
+		"exact": {
+			header: dag.HeaderCondition{Name: "x-canary", MatchType: dag.HeaderMatchTypeExact, Value: "true"},
+			want:   &route.HeaderMatcher{Name: "x-canary", Value: "true"},
+		},
+		"regex": {
+			header: dag.HeaderCondition{Name: "accept", MatchType: dag.HeaderMatchTypeRegex, Value: ".*v2.*"},
+			want:   &route.HeaderMatcher{Name: "accept", Value: ".*v2.*", Regex: &types.BoolValue{Value: true}},
+		},
+		"present": {
+			header: dag.HeaderCondition{Name: "x-canary", MatchType: dag.HeaderMatchTypePresent},
+			want:   &route.HeaderMatcher{Name: "x-canary"},
+		},
+		"inverted exact": {
+			header: dag.HeaderCondition{Name: "x-canary", MatchType: dag.HeaderMatchTypeExact, Value: "a.b", Invert: true},
+			want:   &route.HeaderMatcher{Name: "x-canary", Value: `^(?!a\.b$).*`, Regex: &types.BoolValue{Value: true}},
+		},
+		"inverted regex": {
+			header: dag.HeaderCondition{Name: "accept", MatchType: dag.HeaderMatchTypeRegex, Value: "a|b", Invert: true},
+			want:   &route.HeaderMatcher{Name: "accept", Value: "^(?!(?:a|b)$).*", Regex: &types.BoolValue{Value: true}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := routematch(&dag.Route{Prefix: "/", Headers: []dag.HeaderCondition{tc.header}})
+			want := prefixmatch("/")
+			want.Headers = []*route.HeaderMatcher{tc.want}
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("want:\n%+v\ngot:\n%+v", want, got)
 			}
 		})
 	}
 }
 
+func TestLongestRouteFirst(t *testing.T) {
+	canary := prefixmatch("/api")
+	canary.Headers = []*route.HeaderMatcher{{Name: "x-canary", Value: "true"}}
+	routes := []route.Route{
+		{Match: prefixmatch("/")},
+		{Match: regexmatch("/api/v[0-9]+")},
+		{Match: prefixmatch("/api")},
+		{Match: pathmatch("/api")},
+		{Match: canary},
+		{Match: prefixmatch("/apiv2")},
+		{Match: regexmatch("/api/.*")},
+		{Match: pathmatch("/api/v1")},
+	}
+	want := []route.Route{
+		{Match: pathmatch("/api/v1")},
+		{Match: pathmatch("/api")},
+		{Match: regexmatch("/api/v[0-9]+")},
+		{Match: regexmatch("/api/.*")},
+		{Match: prefixmatch("/apiv2")},
+		{Match: canary},
+		{Match: prefixmatch("/api")},
+		{Match: prefixmatch("/")},
+	}
+	sort.Stable(sort.Reverse(longestRouteFirst(routes)))
+	if !reflect.DeepEqual(want, routes) {
+		t.Fatalf("want:\n%+v\ngot:\n%+v", want, routes)
+	}
+}
+
+func TestRouteVirtualHostHeadersPolicy(t *testing.T) {
+	var b dag.Builder
+	b.Insert(service("default", "kuard", v1.ServicePort{
+		Protocol:   "TCP",
+		Port:       8080,
+		TargetPort: intstr.FromInt(8080),
+	}))
+	b.Insert(&ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "root",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			VirtualHost: ingressroutev1.VirtualHost{
+				Fqdn: "example.com",
+				HeadersPolicy: ingressroutev1.HeadersPolicy{
+					RequestHeadersToAdd:     []ingressroutev1.HeaderValue{{Name: "X-Request-Start", Value: "%START_TIME%"}},
+					ResponseHeadersToAdd:    []ingressroutev1.HeaderValue{{Name: "Strict-Transport-Security", Value: "max-age=31536000"}},
+					ResponseHeadersToRemove: []string{"x-debug"},
+				},
+			},
+			Routes: []ingressroutev1.Route{{
+				Match: "/",
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	})
+	var vh *dag.VirtualHost
+	b.Build().Visit(func(v dag.Vertex) {
+		if v, ok := v.(*dag.VirtualHost); ok {
+			vh = v
+		}
+	})
+	got := routevirtualhost(vh, "80")
+
+	want := &route.VirtualHost{
+		Name:    "example.com",
+		Domains: []string{"example.com", "example.com:80"},
+		Routes: []route.Route{{
+			Match:  prefixmatch("/"),
+			Action: actionroute([]dag.Backend{{Service: &dag.Service{Namespace: "default", Name: "kuard", Port: "8080"}}}),
+		}},
+		RequestHeadersToAdd: []*core.HeaderValueOption{{
+			Header: &core.HeaderValue{Key: "X-Request-Start", Value: "%START_TIME%"},
+		}},
+		ResponseHeadersToAdd: []*core.HeaderValueOption{{
+			Header: &core.HeaderValue{Key: "Strict-Transport-Security", Value: "max-age=31536000"},
+		}},
+		ResponseHeadersToRemove: []string{"x-debug"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want:\n%+v\ngot:\n%+v", want, got)
+	}
+}
+
+func TestRouteVirtualHostRedirect(t *testing.T) {
+	tests := map[string]struct {
+		redirect *ingressroutev1.Redirect
+		want     *route.RedirectAction
+	}{
+		"host": {
+			redirect: &ingressroutev1.Redirect{Host: "example.com"},
+			want: &route.RedirectAction{
+				HostRedirect: "example.com",
+				ResponseCode: route.RedirectAction_MOVED_PERMANENTLY,
+			},
+		},
+		"path": {
+			redirect: &ingressroutev1.Redirect{Path: "/new", StatusCode: 302},
+			want: &route.RedirectAction{
+				PathRewriteSpecifier: &route.RedirectAction_PathRedirect{PathRedirect: "/new"},
+				ResponseCode:         route.RedirectAction_FOUND,
+			},
+		},
+		"https": {
+			redirect: &ingressroutev1.Redirect{Host: "example.com", Scheme: "https", StatusCode: 307},
+			want: &route.RedirectAction{
+				HostRedirect:  "example.com",
+				HttpsRedirect: true,
+				ResponseCode:  route.RedirectAction_TEMPORARY_REDIRECT,
+			},
+		},
+		"permanent": {
+			redirect: &ingressroutev1.Redirect{Host: "example.com", StatusCode: 308},
+			want: &route.RedirectAction{
+				HostRedirect: "example.com",
+				ResponseCode: route.RedirectAction_PERMANENT_REDIRECT,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b dag.Builder
+			b.Insert(&ingressroutev1.IngressRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "legacy",
+					Namespace: "default",
+				},
+				Spec: ingressroutev1.IngressRouteSpec{
+					VirtualHost: ingressroutev1.VirtualHost{
+						Fqdn: "legacy.example.com",
+					},
+					Routes: []ingressroutev1.Route{{
+						Match:    "/",
+						Redirect: tc.redirect,
+					}},
+				},
+			})
+			var got []route.Route
+			b.Build().Visit(func(v dag.Vertex) {
+				if vh, ok := v.(*dag.VirtualHost); ok {
+					got = routevirtualhost(vh, "80").Routes
+				}
+			})
+			want := []route.Route{{
+				Match:  prefixmatch("/"),
+				Action: &route.Route_Redirect{Redirect: tc.want},
+			}}
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("want:\n%+v\ngot:\n%+v", want, got)
+			}
+		})
+	}
+}
+
+// vhostcontents returns the contents of the cache for the supplied vhost.
+func vhostcontents(c *virtualHostCache, vhost string) []proto.Message {
+	name := hashname(60, vhost)
+	return c.Values(func(n string) bool { return n == name })
+}
+
 type virtualHostsByName []proto.Message
 
 func (v virtualHostsByName) Len() int      { return len(v) }
@@ -0,0 +1,88 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"strings"
+
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	"k8s.io/api/extensions/v1beta1"
+)
+
+const (
+	// set docs/annotations.md for details of how these annotations
+	// are applied by Contour.
+
+	annotationWebsocketRoutes  = "contour.heptio.com/websocket-routes"
+	annotationMinProtoVersion  = "contour.heptio.com/tls-minimum-protocol-version"
+	annotationAllowHTTP        = "kubernetes.io/ingress.allow-http"
+	annotationForceSSLRedirect = "ingress.kubernetes.io/force-ssl-redirect"
+	annotationPrefixRewrite    = "contour.heptio.com/prefix-rewrite"
+
+	annotationClientCASecret      = "contour.heptio.com/tls-client-ca-secret"
+	annotationForwardClientCert   = "contour.heptio.com/tls-forward-client-cert"
+	annotationUpstreamCASecret    = "contour.heptio.com/upstream-ca-secret"
+	annotationUpstreamSubjectName = "contour.heptio.com/upstream-subject-name"
+)
+
+// httpAllowed returns true unless the kubernetes.io/ingress.allow-http annotation is
+// present and set to false.
+func httpAllowed(i *v1beta1.Ingress) bool {
+	return !(i.Annotations[annotationAllowHTTP] == "false")
+}
+
+// tlsRequired returns true if the ingress.kubernetes.io/force-ssl-redirect annotation is
+// present and set to true.
+func tlsRequired(i *v1beta1.Ingress) bool {
+	return i.Annotations[annotationForceSSLRedirect] == "true"
+}
+
+// forwardClientCert returns true if the contour.heptio.com/tls-forward-client-cert
+// annotation is present and set to true.
+func forwardClientCert(i *v1beta1.Ingress) bool {
+	return i.Annotations[annotationForwardClientCert] == "true"
+}
+
+// prefixRewrite returns the value of the contour.heptio.com/prefix-rewrite
+// annotation, which replaces the matched path prefix of each route of i.
+func prefixRewrite(i *v1beta1.Ingress) string {
+	return i.Annotations[annotationPrefixRewrite]
+}
+
+// websocketRoutes returns a map of websocket routes. If the value is not present, or
+// malformed, then an empty map is returned.
+func websocketRoutes(i *v1beta1.Ingress) map[string]bool {
+	routes := make(map[string]bool)
+	for _, v := range strings.Split(i.Annotations[annotationWebsocketRoutes], ",") {
+		route := strings.TrimSpace(v)
+		if route != "" {
+			routes[route] = true
+		}
+	}
+	return routes
+}
+
+// minProtoVersion returns the minimum TLS protocol version requested by the
+// contour.heptio.com/tls-minimum-protocol-version annotation. Any value other
+// than "1.2" or "1.3" is interpreted as TLS/1.1.
+func minProtoVersion(i *v1beta1.Ingress) auth.TlsParameters_TlsProtocol {
+	switch i.Annotations[annotationMinProtoVersion] {
+	case "1.3":
+		return auth.TlsParameters_TLSv1_3
+	case "1.2":
+		return auth.TlsParameters_TLSv1_2
+	default:
+		return auth.TlsParameters_TLSv1_1
+	}
+}
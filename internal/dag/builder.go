@@ -0,0 +1,868 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
+	"github.com/heptio/contour/internal/k8s"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+)
+
+// A Builder builds a DAG from a set of Kubernetes objects.
+// The zero value is ready to use.
+type Builder struct {
+	// DefaultSecret, if set, names the Secret served to TLS clients
+	// which send no SNI name or a name matching no secure virtual
+	// host, unless an Ingress TLS block without hosts names one.
+	DefaultSecret k8s.FullName
+
+	ingresses     map[meta]*v1beta1.Ingress
+	ingressroutes map[meta]*ingressroutev1.IngressRoute
+	services      map[meta]*v1.Service
+	secrets       map[meta]*v1.Secret
+}
+
+type meta struct {
+	name, namespace string
+}
+
+// Insert adds obj to the set of objects from which the DAG is built.
+// obj must be a *v1beta1.Ingress, *ingressroutev1.IngressRoute,
+// *v1.Service, or *v1.Secret, other types are ignored.
+func (b *Builder) Insert(obj interface{}) {
+	switch obj := obj.(type) {
+	case *v1beta1.Ingress:
+		if b.ingresses == nil {
+			b.ingresses = make(map[meta]*v1beta1.Ingress)
+		}
+		b.ingresses[meta{name: obj.Name, namespace: obj.Namespace}] = obj
+	case *ingressroutev1.IngressRoute:
+		if b.ingressroutes == nil {
+			b.ingressroutes = make(map[meta]*ingressroutev1.IngressRoute)
+		}
+		b.ingressroutes[meta{name: obj.Name, namespace: obj.Namespace}] = obj
+	case *v1.Service:
+		if b.services == nil {
+			b.services = make(map[meta]*v1.Service)
+		}
+		b.services[meta{name: obj.Name, namespace: obj.Namespace}] = obj
+	case *v1.Secret:
+		if b.secrets == nil {
+			b.secrets = make(map[meta]*v1.Secret)
+		}
+		b.secrets[meta{name: obj.Name, namespace: obj.Namespace}] = obj
+	default:
+		// ignore
+	}
+}
+
+// Build returns a new DAG computed from the objects inserted into b.
+func (b *Builder) Build() *DAG {
+	bb := builder{
+		source:   b,
+		services: make(map[servicemeta]*Service),
+		secrets:  make(map[meta]*Secret),
+		vhosts:   make(map[string]*VirtualHost),
+		svhosts:  make(map[string]*SecureVirtualHost),
+		statuses: make(map[meta]Status),
+	}
+	bb.computeServices()
+	bb.computeIngresses()
+	bb.computeIngressRoutes()
+	bb.computeDefaultSecret()
+	bb.computeClientValidation()
+	return bb.dag()
+}
+
+type servicemeta struct {
+	name, namespace, port string
+}
+
+// builder holds the state of a single call to Builder.Build.
+type builder struct {
+	source *Builder
+
+	services map[servicemeta]*Service
+	secrets  map[meta]*Secret
+	vhosts   map[string]*VirtualHost
+	svhosts  map[string]*SecureVirtualHost
+	statuses map[meta]Status
+}
+
+// computeServices adds a Service for each TCP port of each Kubernetes
+// Service, by number and, if the port is named, by name.
+func (b *builder) computeServices() {
+	for _, svc := range b.source.services {
+		for i := range svc.Spec.Ports {
+			p := &svc.Spec.Ports[i]
+			if p.Protocol != v1.ProtocolTCP {
+				// ignore UDP and other port types.
+				continue
+			}
+			b.addService(svc, p, strconv.Itoa(int(p.Port)))
+			if p.Name != "" {
+				b.addService(svc, p, p.Name)
+			}
+		}
+	}
+}
+
+func (b *builder) addService(svc *v1.Service, p *v1.ServicePort, port string) {
+	s := &Service{
+		Namespace:   svc.Namespace,
+		Name:        svc.Name,
+		Port:        port,
+		Object:      svc,
+		ServicePort: p,
+	}
+	if ca := svc.Annotations[annotationUpstreamCASecret]; ca != "" {
+		b.setUpstreamValidation(s, svc.Namespace, ca, svc.Annotations[annotationUpstreamSubjectName])
+	}
+	b.services[servicemeta{name: svc.Name, namespace: svc.Namespace, port: port}] = s
+}
+
+// setUpstreamValidation records the CA secret, named by an object in
+// namespace, and subject name used to verify the endpoints of s, unless
+// s is already verified. If the CA secret does not exist the validation
+// is still recorded, so that s is not reached without verification.
+func (b *builder) setUpstreamValidation(s *Service, namespace, caSecret, subjectName string) {
+	if s.UpstreamValidation != nil {
+		// the first validation wins.
+		return
+	}
+	s.UpstreamValidation = &UpstreamValidation{
+		CACertificate: b.lookupCASecret(namespace, caSecret),
+		SubjectName:   subjectName,
+	}
+}
+
+// lookupService returns the Service for the named service port. If
+// no such service port exists, a Service without an Object is returned.
+func (b *builder) lookupService(namespace, name, port string) *Service {
+	m := servicemeta{name: name, namespace: namespace, port: port}
+	s, ok := b.services[m]
+	if !ok {
+		s = &Service{
+			Namespace: namespace,
+			Name:      name,
+			Port:      port,
+		}
+		b.services[m] = s
+	}
+	return s
+}
+
+// lookupSecret returns the Secret for the named secret, or nil if the
+// secret does not exist or does not contain both a certificate and a key.
+func (b *builder) lookupSecret(namespace, name string) *Secret {
+	m := meta{name: name, namespace: namespace}
+	if s, ok := b.secrets[m]; ok {
+		return s
+	}
+	secret, ok := b.source.secrets[m]
+	if !ok {
+		return nil
+	}
+	_, cert := secret.Data[v1.TLSCertKey]
+	_, key := secret.Data[v1.TLSPrivateKeyKey]
+	if !cert || !key {
+		return nil
+	}
+	s := &Secret{Object: secret}
+	b.secrets[m] = s
+	return s
+}
+
+// lookupCASecret returns the Secret for the named secret, or nil if
+// the secret does not exist or does not contain a CA certificate.
+func (b *builder) lookupCASecret(namespace, name string) *Secret {
+	secret, ok := b.source.secrets[meta{name: name, namespace: namespace}]
+	if !ok {
+		return nil
+	}
+	if _, ok := secret.Data[CACertificateKey]; !ok {
+		return nil
+	}
+	return &Secret{Object: secret}
+}
+
+func (b *builder) lookupVirtualHost(host string) *VirtualHost {
+	vh, ok := b.vhosts[host]
+	if !ok {
+		vh = &VirtualHost{Host: host}
+		b.vhosts[host] = vh
+	}
+	return vh
+}
+
+func (b *builder) lookupSecureVirtualHost(host string) *SecureVirtualHost {
+	svh, ok := b.svhosts[host]
+	if !ok {
+		svh = &SecureVirtualHost{VirtualHost: VirtualHost{Host: host}}
+		b.svhosts[host] = svh
+	}
+	return svh
+}
+
+// setSecret records the secret named by an object in namespace as the
+// secret of svh, unless svh already has a secret.
+func (b *builder) setSecret(svh *SecureVirtualHost, namespace, name string, minProtoVersion auth.TlsParameters_TlsProtocol) {
+	if svh.Secret != nil {
+		// the first valid secret wins.
+		return
+	}
+	if secret := b.lookupSecret(namespace, name); secret != nil {
+		svh.Secret = secret
+		svh.MinProtoVersion = minProtoVersion
+	}
+}
+
+// setClientValidation records the CA secret, named by an object in
+// namespace, used to verify the clients of svh, unless svh already
+// verifies its clients. If the CA secret does not exist the validation
+// is still recorded, so that svh is not served without verification.
+func (b *builder) setClientValidation(svh *SecureVirtualHost, namespace, caSecret string, forward bool) {
+	if svh.ClientValidation != nil {
+		// the first validation wins.
+		return
+	}
+	svh.ClientValidation = &ClientValidation{
+		CACertificate:            b.lookupCASecret(namespace, caSecret),
+		ForwardClientCertificate: forward,
+	}
+}
+
+// computeIngresses adds the routes of every Ingress to their virtual hosts.
+func (b *builder) computeIngresses() {
+	keys := make([]meta, 0, len(b.source.ingresses))
+	for m := range b.source.ingresses {
+		keys = append(keys, m)
+	}
+	for _, m := range sortMeta(keys) {
+		i := b.source.ingresses[m]
+
+		// tls records the secure virtual hosts of i.
+		tls := make(map[string]*SecureVirtualHost)
+		for _, t := range i.Spec.TLS {
+			if t.SecretName == "" {
+				// not a valid TLS spec without a secret for the cert.
+				continue
+			}
+			hosts := t.Hosts
+			if len(hosts) == 0 {
+				// a TLS spec without hosts applies to every host.
+				hosts = []string{"*"}
+			}
+			for _, host := range hosts {
+				if !validHost(host) {
+					continue
+				}
+				svh := b.lookupSecureVirtualHost(host)
+				b.setSecret(svh, i.Namespace, t.SecretName, minProtoVersion(i))
+				if ca := i.Annotations[annotationClientCASecret]; ca != "" {
+					b.setClientValidation(svh, i.Namespace, ca, forwardClientCert(i))
+				}
+				tls[host] = svh
+			}
+		}
+
+		allowHTTP := httpAllowed(i)
+		upgrade := tlsRequired(i)
+		ws := websocketRoutes(i)
+
+		if i.Spec.Backend != nil && allowHTTP {
+			r := b.ingressRoute(i, "/", i.Spec.Backend, ws)
+			r.HTTPSUpgrade = upgrade
+			b.lookupVirtualHost("*").addRoute(r)
+		}
+
+		for _, rule := range i.Spec.Rules {
+			if rule.IngressRuleValue.HTTP == nil {
+				// TODO(dfc) plumb a logger in here so we can log this error.
+				continue
+			}
+			host := rule.Host
+			if host == "" {
+				// If the host is unspecified, the Ingress routes all traffic based on the specified IngressRuleValue.
+				host = "*"
+			}
+			if !validHost(host) {
+				continue
+			}
+			svh, secure := tls[host]
+			if !secure {
+				// a wildcard TLS host secures the names it matches.
+				if w, ok := tls[wildcard(host)]; ok && w.Secret != nil {
+					svh = b.lookupSecureVirtualHost(host)
+					if svh.Secret == nil {
+						svh.Secret = w.Secret
+						svh.MinProtoVersion = w.MinProtoVersion
+					}
+					if svh.ClientValidation == nil {
+						svh.ClientValidation = w.ClientValidation
+					}
+					tls[host], secure = svh, true
+				}
+			}
+			for n := range rule.IngressRuleValue.HTTP.Paths {
+				p := &rule.IngressRuleValue.HTTP.Paths[n]
+				if allowHTTP {
+					r := b.ingressRoute(i, p.Path, &p.Backend, ws)
+					r.HTTPSUpgrade = upgrade
+					b.lookupVirtualHost(host).addRoute(r)
+				}
+				if secure {
+					svh.addRoute(b.ingressRoute(i, p.Path, &p.Backend, ws))
+				}
+			}
+		}
+	}
+}
+
+// ingressRoute returns a Route matching path which forwards to be.
+func (b *builder) ingressRoute(i *v1beta1.Ingress, path string, be *v1beta1.IngressBackend, ws map[string]bool) *Route {
+	if path == "" {
+		// If the Path is empty, the k8s spec says
+		// "If unspecified, the path defaults to a catch all sending
+		// traffic to the backend."
+		// We map this it a catch all prefix route.
+		path = "/"
+	}
+	r := &Route{
+		Object:    i,
+		Websocket: ws[path],
+		Backends: []Backend{{
+			Service: b.lookupService(i.Namespace, be.ServiceName, be.ServicePort.String()),
+		}},
+	}
+	// TODO(dfc) handle the case where path does not start with "/"
+	if strings.IndexAny(path, `[(*\`) == -1 {
+		// Envoy requires that regex matches match completely, wheres the
+		// HTTPIngressPath.Path regex only requires a partial match. eg,
+		// "/foo" matches "/" according to k8s rules, but does not match
+		// according to Envoy.
+		// To deal with this we handle the simple case, a Path without regex
+		// characters as a Envoy prefix route.
+		r.Prefix = path
+		r.PrefixRewrite = prefixRewrite(i)
+	} else {
+		// At this point the path is a regex, which we hope is the same between k8s
+		// IEEE 1003.1 POSIX regex, and Envoys Javascript regex.
+		r.Regex = path
+	}
+	return r
+}
+
+// computeIngressRoutes adds the routes of every valid root IngressRoute,
+// and the valid IngressRoutes they delegate to, to their virtual hosts,
+// and records the status of every IngressRoute.
+func (b *builder) computeIngressRoutes() {
+	// delegated records the IngressRoutes named as the target of a delegation.
+	delegated := make(map[meta]bool)
+	// fqdns records the IngressRoutes which name each fqdn.
+	fqdns := make(map[string][]meta)
+	for m, ir := range b.source.ingressroutes {
+		for _, r := range ir.Spec.Routes {
+			if r.Delegate.Name != "" {
+				delegated[delegateMeta(ir, r.Delegate)] = true
+			}
+		}
+		if fqdn := ir.Spec.VirtualHost.Fqdn; fqdn != "" {
+			fqdns[fqdn] = append(fqdns[fqdn], m)
+		}
+	}
+
+	keys := make([]meta, 0, len(b.source.ingressroutes))
+	for m := range b.source.ingressroutes {
+		keys = append(keys, m)
+	}
+	for _, m := range sortMeta(keys) {
+		ir := b.source.ingressroutes[m]
+		host := ir.Spec.VirtualHost.Fqdn
+		if host == "" {
+			if delegated[m] {
+				// not a root, reachable only through delegation.
+				continue
+			}
+			// IngressRoutes without a fqdn which are not delegated
+			// to are roots of the default vhost.
+			host = "*"
+		}
+		if !validHost(host) {
+			b.setStatus(ir, StatusInvalid, fmt.Sprintf("fqdn %q is not valid", host))
+			continue
+		}
+		if roots := fqdns[host]; len(roots) > 1 {
+			var names []string
+			for _, r := range sortMeta(roots) {
+				names = append(names, r.namespace+"/"+r.name)
+			}
+			b.setStatus(ir, StatusInvalid, fmt.Sprintf("fqdn %q is used in multiple IngressRoutes: %s", host, strings.Join(names, ", ")))
+			continue
+		}
+
+		routes, ok := b.delegatedRoutes(ir, new(Route), make(map[meta]bool))
+		if !ok {
+			// an invalid root does not delegate.
+			continue
+		}
+		policy := headersPolicy(ir.Spec.VirtualHost.HeadersPolicy)
+		vh := b.lookupVirtualHost(host)
+		for _, a := range ir.Spec.VirtualHost.Aliases {
+			vh.addAlias(a)
+		}
+		if vh.HeadersPolicy == nil {
+			vh.HeadersPolicy = policy
+		}
+		for _, r := range routes {
+			vh.addRoute(r)
+		}
+
+		if host == "*" || ir.Spec.VirtualHost.TLS.SecretName == "" {
+			continue
+		}
+		svh := b.lookupSecureVirtualHost(host)
+		b.setSecret(svh, ir.Namespace, ir.Spec.VirtualHost.TLS.SecretName, auth.TlsParameters_TLSv1_1)
+		if cv := ir.Spec.VirtualHost.TLS.ClientValidation; cv != nil {
+			b.setClientValidation(svh, ir.Namespace, cv.CACertificate, cv.ForwardClientCertificate)
+		}
+		for _, a := range ir.Spec.VirtualHost.Aliases {
+			svh.addAlias(a)
+		}
+		if svh.HeadersPolicy == nil {
+			svh.HeadersPolicy = policy
+		}
+		for _, r := range routes {
+			svh.addRoute(r)
+		}
+	}
+
+	// IngressRoutes not reached from a valid root are orphaned.
+	for m, ir := range b.source.ingressroutes {
+		if _, ok := b.statuses[m]; !ok {
+			b.setStatus(ir, StatusOrphaned, "this IngressRoute is not part of a delegation chain from a root IngressRoute")
+		}
+	}
+}
+
+// setStatus records the status of ir. An invalid status replaces a
+// valid one, otherwise the first status recorded is kept.
+func (b *builder) setStatus(ir *ingressroutev1.IngressRoute, status, desc string) {
+	m := meta{name: ir.Name, namespace: ir.Namespace}
+	if s, ok := b.statuses[m]; ok && (s.Status == StatusInvalid || status != StatusInvalid) {
+		return
+	}
+	b.statuses[m] = Status{
+		Object:      ir,
+		Status:      status,
+		Description: desc,
+	}
+}
+
+// computeDefaultSecret sets the secret of the default secure virtual host,
+// which matches any name, to the secret named by DefaultSecret.
+func (b *builder) computeDefaultSecret() {
+	s := b.source.DefaultSecret
+	if s.Name == "" || b.lookupSecret(s.Namespace, s.Name) == nil {
+		return
+	}
+	b.setSecret(b.lookupSecureVirtualHost("*"), s.Namespace, s.Name, auth.TlsParameters_TLSv1_1)
+}
+
+// computeClientValidation removes the virtual host of every secure
+// virtual host which verifies its clients, so that its routes cannot
+// be reached over HTTP without a client certificate.
+func (b *builder) computeClientValidation() {
+	for host := range b.vhosts {
+		if svh, ok := b.svhosts[host]; ok && svh.ClientValidation != nil {
+			delete(b.vhosts, host)
+		}
+	}
+}
+
+// validHost returns true if host is "*", a name, or a wildcard
+// name whose leftmost label is "*", such as "*.example.com".
+func validHost(host string) bool {
+	if host == "*" {
+		return true
+	}
+	return !strings.Contains(strings.TrimPrefix(host, "*."), "*")
+}
+
+// wildcard returns the wildcard name matching host, host with its
+// leftmost label replaced by "*", or "" if host has a single label.
+func wildcard(host string) string {
+	i := strings.Index(host, ".")
+	if i < 0 {
+		return ""
+	}
+	return "*" + host[i:]
+}
+
+// delegatedRoutes returns the routes of ir, following any delegations to
+// other IngressRoutes, and records the status of ir and the IngressRoutes
+// it delegates to. parent is the route which delegated to ir, only routes
+// which match within its prefix are included, and each inherits its
+// header conditions and prefix rewrite. visited records the IngressRoutes
+// on the current delegation path. If ir is invalid, delegatedRoutes
+// returns false and ir contributes no routes. A route which forwards to a
+// service port which does not exist is still served, with no endpoints,
+// and reported in the status of ir.
+func (b *builder) delegatedRoutes(ir *ingressroutev1.IngressRoute, parent *Route, visited map[meta]bool) ([]*Route, bool) {
+	m := meta{name: ir.Name, namespace: ir.Namespace}
+	visited[m] = true
+	defer delete(visited, m)
+
+	if err := b.validateIngressRoute(ir, visited); err != nil {
+		b.setStatus(ir, StatusInvalid, err.Error())
+		return nil, false
+	}
+	b.setStatus(ir, StatusValid, "valid IngressRoute")
+
+	var routes []*Route
+	for _, r := range ir.Spec.Routes {
+		if !matchesWithin(r, parent.Prefix) {
+			// this route escapes the prefix delegated to this ingressroute, skip it.
+			continue
+		}
+		conditions, _ := headerConditions(r.Headers)
+		route := &Route{
+			// copy headers so routes do not share a backing array.
+			Headers:       append(append([]HeaderCondition(nil), parent.Headers...), conditions...),
+			PrefixRewrite: r.PrefixRewrite,
+			Object:        ir,
+		}
+		switch r.MatchType {
+		case "exact":
+			route.Exact = r.Match
+		case "regex":
+			route.Regex = r.Match
+		default:
+			route.Prefix = r.Match
+		}
+		if route.PrefixRewrite == "" && parent.PrefixRewrite != "" && route.Regex == "" {
+			// rewrite the part of the path delegated to ir.
+			route.PrefixRewrite = parent.PrefixRewrite + strings.TrimPrefix(r.Match, parent.Prefix)
+		}
+		if rd := r.Redirect; rd != nil {
+			route.Redirect = &Redirect{
+				Host:       rd.Host,
+				Path:       rd.Path,
+				HTTPS:      rd.Scheme == "https",
+				StatusCode: rd.StatusCode,
+			}
+			if route.Redirect.StatusCode == 0 {
+				route.Redirect.StatusCode = 301
+			}
+			routes = append(routes, route)
+			continue
+		}
+		if r.Delegate.Name != "" {
+			child, ok := b.source.ingressroutes[delegateMeta(ir, r.Delegate)]
+			if !ok {
+				// delegate not present yet, skip it.
+				continue
+			}
+			delegated, _ := b.delegatedRoutes(child, route, visited)
+			routes = append(routes, delegated...)
+			continue
+		}
+		for _, s := range r.Services {
+			svc := b.lookupService(ir.Namespace, s.Name, strconv.Itoa(s.Port))
+			if svc.Object == nil {
+				b.setStatus(ir, StatusInvalid, fmt.Sprintf("route %q: service %s/%s: port %d not found", r.Match, ir.Namespace, s.Name, s.Port))
+			}
+			if uv := s.UpstreamValidation; uv != nil {
+				b.setUpstreamValidation(svc, ir.Namespace, uv.CACertificate, uv.SubjectName)
+			}
+			route.Backends = append(route.Backends, Backend{
+				Service: svc,
+				Weight:  s.Weight,
+			})
+		}
+		routes = append(routes, route)
+	}
+	return routes, true
+}
+
+// validateIngressRoute returns an error if any route of ir is invalid,
+// or delegates to an IngressRoute on the delegation path visited.
+func (b *builder) validateIngressRoute(ir *ingressroutev1.IngressRoute, visited map[meta]bool) error {
+	for _, r := range ir.Spec.Routes {
+		if err := validateRoute(r); err != nil {
+			return fmt.Errorf("route %q: %v", r.Match, err)
+		}
+		if r.Delegate.Name != "" {
+			if child := delegateMeta(ir, r.Delegate); visited[child] {
+				return fmt.Errorf("route %q: delegation to %s/%s creates a cycle", r.Match, child.namespace, child.name)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRoute returns an error if r is invalid. An
+// IngressRoute with an invalid route is itself invalid.
+func validateRoute(r ingressroutev1.Route) error {
+	switch r.MatchType {
+	case "", "prefix":
+	case "exact":
+	case "regex":
+		if err := validateRegex(r.Match); err != nil {
+			return fmt.Errorf("invalid regex: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown matchType %q", r.MatchType)
+	}
+	if r.Delegate.Name != "" && r.MatchType != "" && r.MatchType != "prefix" {
+		return fmt.Errorf("delegation requires a prefix match, not %s", r.MatchType)
+	}
+	if r.PrefixRewrite != "" && r.MatchType == "regex" {
+		return errors.New("prefixRewrite requires a prefix or exact match")
+	}
+	if r.Redirect != nil {
+		if err := validateRedirect(r); err != nil {
+			return err
+		}
+	}
+	_, err := headerConditions(r.Headers)
+	return err
+}
+
+// validateRedirect returns an error if the redirect of r is invalid.
+func validateRedirect(r ingressroutev1.Route) error {
+	rd := r.Redirect
+	if len(r.Services) > 0 || r.Delegate.Name != "" || r.PrefixRewrite != "" {
+		return errors.New("redirect cannot be combined with services, delegate, or prefixRewrite")
+	}
+	switch rd.Scheme {
+	case "", "https":
+	default:
+		// Envoy can only redirect to https.
+		return fmt.Errorf("redirect scheme %q is not supported, only https", rd.Scheme)
+	}
+	switch rd.StatusCode {
+	case 0, 301, 302, 307, 308:
+	default:
+		return fmt.Errorf("redirect statusCode %d is not one of 301, 302, 307, or 308", rd.StatusCode)
+	}
+	if rd.Host == "" && rd.Path == "" && rd.Scheme == "" {
+		return errors.New("redirect must set host, path, or scheme")
+	}
+	return nil
+}
+
+// validateRegex returns an error if expr is not a regular expression
+// which Envoy and Contour read alike. Envoy matches with the ECMAScript
+// grammar of std::regex, while Contour parses with Go's RE2 syntax, so
+// only their common syntax is accepted. Lookaround and backreferences
+// are ECMAScript only, flags and named groups are RE2 only.
+func validateRegex(expr string) error {
+	inClass := false
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case c == '\\':
+			if i+1 < len(expr) && expr[i+1] >= '1' && expr[i+1] <= '9' && !inClass {
+				return fmt.Errorf("backreference %q is not supported", expr[i:i+2])
+			}
+			i++ // skip the escaped character.
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			// a ] first in the class, after any ^, is literal.
+			if i+1 < len(expr) && expr[i+1] == '^' {
+				i++
+			}
+			if i+1 < len(expr) && expr[i+1] == ']' {
+				i++
+			}
+		case c == '(' && strings.HasPrefix(expr[i:], "(?"):
+			group := expr[i:]
+			switch {
+			case strings.HasPrefix(group, "(?:"):
+				// non capturing groups are common to both.
+			case strings.HasPrefix(group, "(?="), strings.HasPrefix(group, "(?!"):
+				return fmt.Errorf("lookahead %q is not supported", group[:3])
+			case strings.HasPrefix(group, "(?<="), strings.HasPrefix(group, "(?<!"):
+				return fmt.Errorf("lookbehind %q is not supported", group[:4])
+			default:
+				if end := strings.IndexByte(group, ')'); end > 0 {
+					group = group[:end+1]
+				}
+				return fmt.Errorf("flags and named groups are not supported: %q", group)
+			}
+		}
+	}
+	_, err := regexp.Compile(expr)
+	return err
+}
+
+// matchesWithin returns true if every path matched by r begins with prefix.
+// r must be valid.
+func matchesWithin(r ingressroutev1.Route, prefix string) bool {
+	match := r.Match
+	if r.MatchType == "regex" {
+		// Envoy matches the whole path, so every path
+		// matched begins with the literal prefix of match.
+		match, _ = regexp.MustCompile(match).LiteralPrefix()
+	}
+	return strings.HasPrefix(match, prefix)
+}
+
+// headerConditions returns the HeaderConditions for hs, or an
+// error if any of hs is invalid.
+func headerConditions(hs []ingressroutev1.HeaderCondition) ([]HeaderCondition, error) {
+	var conditions []HeaderCondition
+	for _, h := range hs {
+		if h.Name == "" {
+			return nil, errors.New("header condition without a name")
+		}
+		hc := HeaderCondition{Name: h.Name, Invert: h.Invert}
+		n := 0
+		if h.Exact != "" {
+			hc.MatchType, hc.Value = HeaderMatchTypeExact, h.Exact
+			n++
+		}
+		if h.Regex != "" {
+			if err := validateRegex(h.Regex); err != nil {
+				return nil, fmt.Errorf("header %q: invalid regex: %v", h.Name, err)
+			}
+			hc.MatchType, hc.Value = HeaderMatchTypeRegex, h.Regex
+			n++
+		}
+		if h.Present {
+			hc.MatchType = HeaderMatchTypePresent
+			n++
+		}
+		if n != 1 {
+			return nil, fmt.Errorf("header %q: exactly one of exact, regex, or present must be set", h.Name)
+		}
+		if hc.MatchType == HeaderMatchTypePresent && hc.Invert {
+			// Envoy cannot match the absence of a header.
+			return nil, fmt.Errorf("header %q: present conditions cannot be inverted", h.Name)
+		}
+		conditions = append(conditions, hc)
+	}
+	return conditions, nil
+}
+
+// headersPolicy returns the HeadersPolicy for p, or nil if p is empty.
+// Headers without a name are ignored.
+func headersPolicy(p ingressroutev1.HeadersPolicy) *HeadersPolicy {
+	var hp HeadersPolicy
+	for _, h := range p.RequestHeadersToAdd {
+		if h.Name != "" {
+			hp.RequestHeadersToAdd = append(hp.RequestHeadersToAdd, HeaderValue{Name: h.Name, Value: h.Value})
+		}
+	}
+	for _, h := range p.ResponseHeadersToAdd {
+		if h.Name != "" {
+			hp.ResponseHeadersToAdd = append(hp.ResponseHeadersToAdd, HeaderValue{Name: h.Name, Value: h.Value})
+		}
+	}
+	for _, name := range p.ResponseHeadersToRemove {
+		if name != "" {
+			hp.ResponseHeadersToRemove = append(hp.ResponseHeadersToRemove, name)
+		}
+	}
+	return hp.orNil()
+}
+
+// delegateMeta returns the meta of the IngressRoute named by d. If d does
+// not specify a namespace, the namespace of the delegating IngressRoute is used.
+func delegateMeta(ir *ingressroutev1.IngressRoute, d ingressroutev1.Delegate) meta {
+	ns := d.Namespace
+	if ns == "" {
+		ns = ir.Namespace
+	}
+	return meta{name: d.Name, namespace: ns}
+}
+
+// dag returns a DAG whose roots are the virtual hosts with at least one
+// route, every secure virtual host, and every Service which exists, and
+// which carries the status of every IngressRoute.
+func (b *builder) dag() *DAG {
+	var d DAG
+	hosts := make([]string, 0, len(b.vhosts))
+	for h, vh := range b.vhosts {
+		if len(vh.routes) > 0 {
+			hosts = append(hosts, h)
+		}
+	}
+	sort.Strings(hosts)
+	for _, h := range hosts {
+		d.roots = append(d.roots, b.vhosts[h])
+	}
+
+	hosts = make([]string, 0, len(b.svhosts))
+	for h := range b.svhosts {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	for _, h := range hosts {
+		d.roots = append(d.roots, b.svhosts[h])
+	}
+
+	services := make([]servicemeta, 0, len(b.services))
+	for m, s := range b.services {
+		if s.Object != nil {
+			services = append(services, m)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		x, y := services[i], services[j]
+		if x.namespace != y.namespace {
+			return x.namespace < y.namespace
+		}
+		if x.name != y.name {
+			return x.name < y.name
+		}
+		return x.port < y.port
+	})
+	for _, m := range services {
+		d.roots = append(d.roots, b.services[m])
+	}
+
+	keys := make([]meta, 0, len(b.statuses))
+	for m := range b.statuses {
+		keys = append(keys, m)
+	}
+	for _, m := range sortMeta(keys) {
+		d.statuses = append(d.statuses, b.statuses[m])
+	}
+	return &d
+}
+
+// sortMeta sorts keys in namespace, name order and returns them.
+func sortMeta(keys []meta) []meta {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+		return keys[i].name < keys[j].name
+	})
+	return keys
+}
@@ -0,0 +1,1298 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
+	"github.com/heptio/contour/internal/k8s"
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestBuilderBuild(t *testing.T) {
+	i1 := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: v1beta1.IngressSpec{
+			Backend: &v1beta1.IngressBackend{
+				ServiceName: "kuard",
+				ServicePort: intstr.FromInt(8080),
+			},
+		},
+	}
+	i2 := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{
+				Hosts:      []string{"kuard.example.com"},
+				SecretName: "secret",
+			}},
+			Rules: []v1beta1.IngressRule{{
+				Host:             "kuard.example.com",
+				IngressRuleValue: rulevalue("", "kuard", intstr.FromInt(8080)),
+			}},
+		},
+	}
+	i3 := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"ingress.kubernetes.io/force-ssl-redirect": "true",
+				"contour.heptio.com/websocket-routes":      "/ws",
+			},
+		},
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{
+				Hosts:      []string{"kuard.example.com"},
+				SecretName: "secret",
+			}},
+			Rules: []v1beta1.IngressRule{{
+				Host:             "kuard.example.com",
+				IngressRuleValue: rulevalue("/ws", "kuard", intstr.FromString("http")),
+			}},
+		},
+	}
+	i4 := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "regex",
+			Namespace: "default",
+		},
+		Spec: v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{{
+				IngressRuleValue: rulevalue("/api/v[0-9]+", "kuard", intstr.FromInt(8080)),
+			}},
+		},
+	}
+	s1 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Protocol:   "TCP",
+				Port:       8080,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}
+	s2 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   "TCP",
+				Port:       80,
+				TargetPort: intstr.FromInt(8080),
+			}, {
+				Name:     "dns",
+				Protocol: "UDP",
+				Port:     53,
+			}},
+		},
+	}
+	sec1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			v1.TLSCertKey:       []byte("certificate"),
+			v1.TLSPrivateKeyKey: []byte("key"),
+		},
+	}
+	sec2 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			v1.TLSCertKey: []byte("certificate"),
+		},
+	}
+	weight := 80
+	ir1 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			VirtualHost: ingressroutev1.VirtualHost{
+				Fqdn:    "example.com",
+				Aliases: []string{"www.example.com", "example.com"},
+			},
+			Routes: []ingressroutev1.Route{{
+				Match: "/",
+				Services: []ingressroutev1.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			}, {
+				Match: "/blog",
+				Delegate: ingressroutev1.Delegate{
+					Name:      "blog",
+					Namespace: "marketing",
+				},
+			}},
+		},
+	}
+	ir2 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "blog",
+			Namespace: "marketing",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			Routes: []ingressroutev1.Route{{
+				Match: "/blog",
+				Services: []ingressroutev1.Service{{
+					Name:   "blog",
+					Port:   80,
+					Weight: &weight,
+				}},
+			}, {
+				// escapes the prefix delegated to blog, ignored.
+				Match: "/admin",
+				Services: []ingressroutev1.Service{{
+					Name: "admin",
+					Port: 80,
+				}},
+			}},
+		},
+	}
+	ir3 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			VirtualHost: ingressroutev1.VirtualHost{
+				Fqdn: "example.com",
+				TLS: ingressroutev1.TLS{
+					SecretName: "secret",
+				},
+			},
+			Routes: []ingressroutev1.Route{{
+				Match: "/",
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	kuard := &Service{
+		Namespace:   "default",
+		Name:        "kuard",
+		Port:        "8080",
+		Object:      s1,
+		ServicePort: &s1.Spec.Ports[0],
+	}
+	home := backendService("default", "home", 8080)
+	blog := backendService("marketing", "blog", 80)
+	admin := backendService("marketing", "admin", 80)
+
+	tests := map[string]struct {
+		objs []interface{}
+		want []Vertex
+	}{
+		"empty": {},
+		"service": {
+			objs: []interface{}{s1},
+			want: []Vertex{kuard},
+		},
+		"named service port": {
+			objs: []interface{}{s2},
+			want: []Vertex{
+				&Service{
+					Namespace:   "default",
+					Name:        "kuard",
+					Port:        "80",
+					Object:      s2,
+					ServicePort: &s2.Spec.Ports[0],
+				},
+				&Service{
+					Namespace:   "default",
+					Name:        "kuard",
+					Port:        "http",
+					Object:      s2,
+					ServicePort: &s2.Spec.Ports[0],
+				},
+			},
+		},
+		"default backend": {
+			objs: []interface{}{i1, s1},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "*",
+					routes: []*Route{{
+						Prefix:   "/",
+						Object:   i1,
+						Backends: []Backend{{Service: kuard}},
+					}},
+				},
+				kuard,
+			},
+		},
+		"default backend, missing service": {
+			objs: []interface{}{i1},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "*",
+					routes: []*Route{{
+						Prefix: "/",
+						Object: i1,
+						Backends: []Backend{{
+							Service: &Service{Namespace: "default", Name: "kuard", Port: "8080"},
+						}},
+					}},
+				},
+			},
+		},
+		"regex path": {
+			objs: []interface{}{i4, s1},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "*",
+					routes: []*Route{{
+						Regex:    "/api/v[0-9]+",
+						Object:   i4,
+						Backends: []Backend{{Service: kuard}},
+					}},
+				},
+				kuard,
+			},
+		},
+		"tls": {
+			objs: []interface{}{i2, s1, sec1},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "kuard.example.com",
+					routes: []*Route{{
+						Prefix:   "/",
+						Object:   i2,
+						Backends: []Backend{{Service: kuard}},
+					}},
+				},
+				&SecureVirtualHost{
+					VirtualHost: VirtualHost{
+						Host: "kuard.example.com",
+						routes: []*Route{{
+							Prefix:   "/",
+							Object:   i2,
+							Backends: []Backend{{Service: kuard}},
+						}},
+					},
+					MinProtoVersion: auth.TlsParameters_TLSv1_1,
+					Secret:          &Secret{Object: sec1},
+				},
+				kuard,
+			},
+		},
+		"tls, secret missing private key": {
+			objs: []interface{}{i2, s1, sec2},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "kuard.example.com",
+					routes: []*Route{{
+						Prefix:   "/",
+						Object:   i2,
+						Backends: []Backend{{Service: kuard}},
+					}},
+				},
+				&SecureVirtualHost{
+					VirtualHost: VirtualHost{
+						Host: "kuard.example.com",
+						routes: []*Route{{
+							Prefix:   "/",
+							Object:   i2,
+							Backends: []Backend{{Service: kuard}},
+						}},
+					},
+				},
+				kuard,
+			},
+		},
+		"tls, force https, websocket": {
+			objs: []interface{}{i3, sec1},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "kuard.example.com",
+					routes: []*Route{{
+						Prefix:       "/ws",
+						Object:       i3,
+						Websocket:    true,
+						HTTPSUpgrade: true,
+						Backends: []Backend{{
+							Service: &Service{Namespace: "default", Name: "kuard", Port: "http"},
+						}},
+					}},
+				},
+				&SecureVirtualHost{
+					VirtualHost: VirtualHost{
+						Host: "kuard.example.com",
+						routes: []*Route{{
+							Prefix:    "/ws",
+							Object:    i3,
+							Websocket: true,
+							Backends: []Backend{{
+								Service: &Service{Namespace: "default", Name: "kuard", Port: "http"},
+							}},
+						}},
+					},
+					MinProtoVersion: auth.TlsParameters_TLSv1_1,
+					Secret:          &Secret{Object: sec1},
+				},
+			},
+		},
+		"ingressroute delegation": {
+			objs: []interface{}{ir1, ir2, home.Object, blog.Object, admin.Object},
+			want: []Vertex{
+				&VirtualHost{
+					Host:    "example.com",
+					Aliases: []string{"www.example.com"},
+					routes: []*Route{{
+						Prefix:   "/",
+						Object:   ir1,
+						Backends: []Backend{{Service: home}},
+					}, {
+						Prefix: "/blog",
+						Object: ir2,
+						Backends: []Backend{{
+							Service: blog,
+							Weight:  &weight,
+						}},
+					}},
+				},
+				home,
+				admin,
+				blog,
+			},
+		},
+		"ingressroute delegation, missing service": {
+			objs: []interface{}{ir1, ir2, home.Object, admin.Object},
+			want: []Vertex{
+				// the blog service is missing, its route is
+				// still served and forwards to no endpoints.
+				&VirtualHost{
+					Host:    "example.com",
+					Aliases: []string{"www.example.com"},
+					routes: []*Route{{
+						Prefix:   "/",
+						Object:   ir1,
+						Backends: []Backend{{Service: home}},
+					}, {
+						Prefix: "/blog",
+						Object: ir2,
+						Backends: []Backend{{
+							Service: &Service{Namespace: "marketing", Name: "blog", Port: "80"},
+							Weight:  &weight,
+						}},
+					}},
+				},
+				home,
+				admin,
+			},
+		},
+		"ingressroute delegate without parent": {
+			objs: []interface{}{ir2, blog.Object, admin.Object},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "*",
+					routes: []*Route{{
+						Prefix: "/blog",
+						Object: ir2,
+						Backends: []Backend{{
+							Service: blog,
+							Weight:  &weight,
+						}},
+					}, {
+						Prefix:   "/admin",
+						Object:   ir2,
+						Backends: []Backend{{Service: admin}},
+					}},
+				},
+				admin,
+				blog,
+			},
+		},
+		"ingressroute tls": {
+			objs: []interface{}{ir3, s1, sec1},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "example.com",
+					routes: []*Route{{
+						Prefix:   "/",
+						Object:   ir3,
+						Backends: []Backend{{Service: kuard}},
+					}},
+				},
+				&SecureVirtualHost{
+					VirtualHost: VirtualHost{
+						Host: "example.com",
+						routes: []*Route{{
+							Prefix:   "/",
+							Object:   ir3,
+							Backends: []Backend{{Service: kuard}},
+						}},
+					},
+					MinProtoVersion: auth.TlsParameters_TLSv1_1,
+					Secret:          &Secret{Object: sec1},
+				},
+				kuard,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b Builder
+			for _, o := range tc.objs {
+				b.Insert(o)
+			}
+			var got []Vertex
+			b.Build().Visit(func(v Vertex) {
+				got = append(got, v)
+			})
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("want:\n%+v\ngot:\n%+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBuilderDefaultSecret(t *testing.T) {
+	sec1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			v1.TLSCertKey:       []byte("certificate"),
+			v1.TLSPrivateKeyKey: []byte("key"),
+		},
+	}
+	i1 := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "default",
+		},
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{
+				SecretName: "other",
+			}},
+		},
+	}
+	sec2 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other",
+			Namespace: "default",
+		},
+		Data: sec1.Data,
+	}
+
+	tests := map[string]struct {
+		secret k8s.FullName
+		objs   []interface{}
+		want   []Vertex
+	}{
+		"default secret": {
+			secret: k8s.FullName{Name: "secret", Namespace: "default"},
+			objs:   []interface{}{sec1},
+			want: []Vertex{
+				&SecureVirtualHost{
+					VirtualHost:     VirtualHost{Host: "*"},
+					MinProtoVersion: auth.TlsParameters_TLSv1_1,
+					Secret:          &Secret{Object: sec1},
+				},
+			},
+		},
+		"missing default secret": {
+			secret: k8s.FullName{Name: "missing", Namespace: "default"},
+			objs:   []interface{}{sec1},
+		},
+		"ingress tls without hosts takes precedence": {
+			secret: k8s.FullName{Name: "secret", Namespace: "default"},
+			objs:   []interface{}{i1, sec1, sec2},
+			want: []Vertex{
+				&SecureVirtualHost{
+					VirtualHost:     VirtualHost{Host: "*"},
+					MinProtoVersion: auth.TlsParameters_TLSv1_1,
+					Secret:          &Secret{Object: sec2},
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := Builder{DefaultSecret: tc.secret}
+			for _, o := range tc.objs {
+				b.Insert(o)
+			}
+			var got []Vertex
+			b.Build().Visit(func(v Vertex) {
+				got = append(got, v)
+			})
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("want:\n%+v\ngot:\n%+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBuilderWildcardHosts(t *testing.T) {
+	sec1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			v1.TLSCertKey:       []byte("certificate"),
+			v1.TLSPrivateKeyKey: []byte("key"),
+		},
+	}
+	i1 := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "preview",
+			Namespace: "default",
+		},
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{
+				Hosts:      []string{"*.example.com"},
+				SecretName: "secret",
+			}},
+			Rules: []v1beta1.IngressRule{{
+				Host:             "foo.example.com",
+				IngressRuleValue: rulevalue("", "kuard", intstr.FromInt(8080)),
+			}},
+		},
+	}
+	i2 := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "preview",
+			Namespace: "default",
+		},
+		Spec: v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{{
+				Host:             "*.example.com",
+				IngressRuleValue: rulevalue("", "kuard", intstr.FromInt(8080)),
+			}, {
+				Host:             "foo.*.com",
+				IngressRuleValue: rulevalue("", "kuard", intstr.FromInt(8080)),
+			}},
+		},
+	}
+	ir1 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "preview",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			VirtualHost: ingressroutev1.VirtualHost{
+				Fqdn: "*.example.com",
+			},
+			Routes: []ingressroutev1.Route{{
+				Match: "/",
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+	kuard := &Service{Namespace: "default", Name: "kuard", Port: "8080"}
+	backend := backendService("default", "kuard", 8080)
+
+	tests := map[string]struct {
+		objs []interface{}
+		want []Vertex
+	}{
+		"ingress rule secured by wildcard tls host": {
+			objs: []interface{}{i1, sec1},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "foo.example.com",
+					routes: []*Route{{
+						Prefix:   "/",
+						Object:   i1,
+						Backends: []Backend{{Service: kuard}},
+					}},
+				},
+				&SecureVirtualHost{
+					VirtualHost:     VirtualHost{Host: "*.example.com"},
+					MinProtoVersion: auth.TlsParameters_TLSv1_1,
+					Secret:          &Secret{Object: sec1},
+				},
+				&SecureVirtualHost{
+					VirtualHost: VirtualHost{
+						Host: "foo.example.com",
+						routes: []*Route{{
+							Prefix:   "/",
+							Object:   i1,
+							Backends: []Backend{{Service: kuard}},
+						}},
+					},
+					MinProtoVersion: auth.TlsParameters_TLSv1_1,
+					Secret:          &Secret{Object: sec1},
+				},
+			},
+		},
+		"wildcard ingress rule": {
+			objs: []interface{}{i2},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "*.example.com",
+					routes: []*Route{{
+						Prefix:   "/",
+						Object:   i2,
+						Backends: []Backend{{Service: kuard}},
+					}},
+				},
+			},
+		},
+		"wildcard ingressroute fqdn": {
+			objs: []interface{}{ir1, backend.Object},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "*.example.com",
+					routes: []*Route{{
+						Prefix:   "/",
+						Object:   ir1,
+						Backends: []Backend{{Service: backend}},
+					}},
+				},
+				backend,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b Builder
+			for _, o := range tc.objs {
+				b.Insert(o)
+			}
+			var got []Vertex
+			b.Build().Visit(func(v Vertex) {
+				got = append(got, v)
+			})
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("want:\n%+v\ngot:\n%+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBuilderHeaderConditions(t *testing.T) {
+	canary := ingressroutev1.HeaderCondition{Name: "x-canary", Exact: "true"}
+	accept := ingressroutev1.HeaderCondition{Name: "accept", Regex: ".*v2.*"}
+	ir1 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "root",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			VirtualHost: ingressroutev1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []ingressroutev1.Route{{
+				Match:   "/",
+				Headers: []ingressroutev1.HeaderCondition{canary},
+				Delegate: ingressroutev1.Delegate{
+					Name: "child",
+				},
+			}},
+		},
+	}
+	ir2 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "child",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			Routes: []ingressroutev1.Route{{
+				Match:   "/api",
+				Headers: []ingressroutev1.HeaderCondition{accept},
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	kuard := backendService("default", "kuard", 8080)
+
+	var b Builder
+	b.Insert(ir1)
+	b.Insert(ir2)
+	b.Insert(kuard.Object)
+	var got []Vertex
+	b.Build().Visit(func(v Vertex) {
+		got = append(got, v)
+	})
+	want := []Vertex{
+		&VirtualHost{
+			Host: "example.com",
+			routes: []*Route{{
+				Prefix: "/api",
+				Headers: []HeaderCondition{
+					{Name: "x-canary", MatchType: HeaderMatchTypeExact, Value: "true"},
+					{Name: "accept", MatchType: HeaderMatchTypeRegex, Value: ".*v2.*"},
+				},
+				Object:   ir2,
+				Backends: []Backend{{Service: kuard}},
+			}},
+		},
+		kuard,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want:\n%+v\ngot:\n%+v", want, got)
+	}
+}
+
+func TestBuilderMatchTypes(t *testing.T) {
+	ir1 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "root",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			VirtualHost: ingressroutev1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []ingressroutev1.Route{{
+				Match: "/api",
+				Delegate: ingressroutev1.Delegate{
+					Name: "child",
+				},
+			}, {
+				Match:     "/",
+				MatchType: "exact",
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+	ir2 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "child",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			Routes: []ingressroutev1.Route{{
+				Match:     "/api/v[0-9]+/users",
+				MatchType: "regex",
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}, {
+				// escapes the delegated prefix.
+				Match:     "/(api|admin)/.*",
+				MatchType: "regex",
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+	kuard := backendService("default", "kuard", 8080)
+
+	var b Builder
+	b.Insert(ir1)
+	b.Insert(ir2)
+	b.Insert(kuard.Object)
+	var got []Vertex
+	b.Build().Visit(func(v Vertex) {
+		got = append(got, v)
+	})
+	want := []Vertex{
+		&VirtualHost{
+			Host: "example.com",
+			routes: []*Route{{
+				Regex:    "/api/v[0-9]+/users",
+				Object:   ir2,
+				Backends: []Backend{{Service: kuard}},
+			}, {
+				Exact:    "/",
+				Object:   ir1,
+				Backends: []Backend{{Service: kuard}},
+			}},
+		},
+		kuard,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want:\n%+v\ngot:\n%+v", want, got)
+	}
+}
+
+func TestBuilderPrefixRewrite(t *testing.T) {
+	i1 := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"contour.heptio.com/prefix-rewrite": "/",
+			},
+		},
+		Spec: v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{{
+				Host:             "kuard.example.com",
+				IngressRuleValue: rulevalue("/kuard/", "kuard", intstr.FromInt(8080)),
+			}},
+		},
+	}
+	ir1 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "root",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			VirtualHost: ingressroutev1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []ingressroutev1.Route{{
+				Match:         "/team-x/",
+				PrefixRewrite: "/",
+				Delegate: ingressroutev1.Delegate{
+					Name: "child",
+				},
+			}},
+		},
+	}
+	ir2 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "child",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			Routes: []ingressroutev1.Route{{
+				Match: "/team-x/",
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}, {
+				Match:         "/team-x/api",
+				PrefixRewrite: "/v1/api",
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}, {
+				Match:     "/team-x/status",
+				MatchType: "exact",
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+	kuard := &Service{Namespace: "default", Name: "kuard", Port: "8080"}
+	backend := backendService("default", "kuard", 8080)
+
+	tests := map[string]struct {
+		objs []interface{}
+		want []Vertex
+	}{
+		"ingress annotation": {
+			objs: []interface{}{i1},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "kuard.example.com",
+					routes: []*Route{{
+						Prefix:        "/kuard/",
+						PrefixRewrite: "/",
+						Object:        i1,
+						Backends:      []Backend{{Service: kuard}},
+					}},
+				},
+			},
+		},
+		"delegated prefix rewrite": {
+			objs: []interface{}{ir1, ir2, backend.Object},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "example.com",
+					routes: []*Route{{
+						Prefix:        "/team-x/",
+						PrefixRewrite: "/",
+						Object:        ir2,
+						Backends:      []Backend{{Service: backend}},
+					}, {
+						Prefix:        "/team-x/api",
+						PrefixRewrite: "/v1/api",
+						Object:        ir2,
+						Backends:      []Backend{{Service: backend}},
+					}, {
+						Exact:         "/team-x/status",
+						PrefixRewrite: "/status",
+						Object:        ir2,
+						Backends:      []Backend{{Service: backend}},
+					}},
+				},
+				backend,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b Builder
+			for _, o := range tc.objs {
+				b.Insert(o)
+			}
+			var got []Vertex
+			b.Build().Visit(func(v Vertex) {
+				got = append(got, v)
+			})
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("want:\n%+v\ngot:\n%+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBuilderHeadersPolicy(t *testing.T) {
+	ir1 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "root",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			VirtualHost: ingressroutev1.VirtualHost{
+				Fqdn: "example.com",
+				HeadersPolicy: ingressroutev1.HeadersPolicy{
+					RequestHeadersToAdd: []ingressroutev1.HeaderValue{{
+						Name:  "X-Request-Start",
+						Value: "%START_TIME%",
+					}, {
+						Value: "no name",
+					}},
+					ResponseHeadersToAdd: []ingressroutev1.HeaderValue{{
+						Name:  "Strict-Transport-Security",
+						Value: "max-age=31536000",
+					}},
+					ResponseHeadersToRemove: []string{"x-internal", ""},
+				},
+			},
+			Routes: []ingressroutev1.Route{{
+				Match: "/team-x/",
+				Delegate: ingressroutev1.Delegate{
+					Name: "child",
+				},
+			}},
+		},
+	}
+	ir2 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "child",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			Routes: []ingressroutev1.Route{{
+				Match: "/team-x/",
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+	backend := backendService("default", "kuard", 8080)
+
+	tests := map[string]struct {
+		objs []interface{}
+		want []Vertex
+	}{
+		"ingressroute virtual host and delegated route": {
+			objs: []interface{}{ir1, ir2, backend.Object},
+			want: []Vertex{
+				&VirtualHost{
+					Host: "example.com",
+					HeadersPolicy: &HeadersPolicy{
+						RequestHeadersToAdd:     []HeaderValue{{Name: "X-Request-Start", Value: "%START_TIME%"}},
+						ResponseHeadersToAdd:    []HeaderValue{{Name: "Strict-Transport-Security", Value: "max-age=31536000"}},
+						ResponseHeadersToRemove: []string{"x-internal"},
+					},
+					routes: []*Route{{
+						Prefix:   "/team-x/",
+						Object:   ir2,
+						Backends: []Backend{{Service: backend}},
+					}},
+				},
+				backend,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b Builder
+			for _, o := range tc.objs {
+				b.Insert(o)
+			}
+			var got []Vertex
+			b.Build().Visit(func(v Vertex) {
+				got = append(got, v)
+			})
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("want:\n%+v\ngot:\n%+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBuilderClientValidation(t *testing.T) {
+	sec := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			v1.TLSCertKey:       []byte("certificate"),
+			v1.TLSPrivateKeyKey: []byte("key"),
+		},
+	}
+	ca1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ca1",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			CACertificateKey: []byte("ca1"),
+		},
+	}
+	ca2 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ca2",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			CACertificateKey: []byte("ca2"),
+		},
+	}
+	ir1 := &ingressroutev1.IngressRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "root",
+			Namespace: "default",
+		},
+		Spec: ingressroutev1.IngressRouteSpec{
+			VirtualHost: ingressroutev1.VirtualHost{
+				Fqdn: "example.com",
+				TLS: ingressroutev1.TLS{
+					SecretName: "secret",
+					ClientValidation: &ingressroutev1.ClientValidation{
+						CACertificate:            "ca1",
+						ForwardClientCertificate: true,
+					},
+				},
+			},
+			Routes: []ingressroutev1.Route{{
+				Match: "/",
+				Services: []ingressroutev1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+	// i1 and i2 verify the clients of the same host with different CAs.
+	i1 := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "a",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"contour.heptio.com/tls-client-ca-secret": "ca1",
+			},
+		},
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{
+				Hosts:      []string{"example.com"},
+				SecretName: "secret",
+			}},
+			Rules: []v1beta1.IngressRule{{
+				Host:             "example.com",
+				IngressRuleValue: rulevalue("/", "kuard", intstr.FromInt(8080)),
+			}},
+		},
+	}
+	i2 := &v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "b",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"contour.heptio.com/tls-client-ca-secret":    "ca2",
+				"contour.heptio.com/tls-forward-client-cert": "true",
+			},
+		},
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{
+				Hosts:      []string{"example.com"},
+				SecretName: "secret",
+			}},
+		},
+	}
+	kuard := backendService("default", "kuard", 8080)
+
+	tests := map[string]struct {
+		objs []interface{}
+		want []Vertex
+	}{
+		"ca secret missing": {
+			objs: []interface{}{ir1, sec, kuard.Object},
+			want: []Vertex{
+				// clients cannot be verified, the validation is
+				// kept so the host is not served without it.
+				&SecureVirtualHost{
+					VirtualHost: VirtualHost{
+						Host: "example.com",
+						routes: []*Route{{
+							Prefix:   "/",
+							Object:   ir1,
+							Backends: []Backend{{Service: kuard}},
+						}},
+					},
+					MinProtoVersion: auth.TlsParameters_TLSv1_1,
+					Secret:          &Secret{Object: sec},
+					ClientValidation: &ClientValidation{
+						ForwardClientCertificate: true,
+					},
+				},
+				kuard,
+			},
+		},
+		"ca secret": {
+			objs: []interface{}{ir1, sec, ca1, kuard.Object},
+			want: []Vertex{
+				// the virtual host is not served over HTTP.
+				&SecureVirtualHost{
+					VirtualHost: VirtualHost{
+						Host: "example.com",
+						routes: []*Route{{
+							Prefix:   "/",
+							Object:   ir1,
+							Backends: []Backend{{Service: kuard}},
+						}},
+					},
+					MinProtoVersion: auth.TlsParameters_TLSv1_1,
+					Secret:          &Secret{Object: sec},
+					ClientValidation: &ClientValidation{
+						CACertificate:            &Secret{Object: ca1},
+						ForwardClientCertificate: true,
+					},
+				},
+				kuard,
+			},
+		},
+		"first validation wins": {
+			objs: []interface{}{i2, i1, sec, ca1, ca2, kuard.Object},
+			want: []Vertex{
+				&SecureVirtualHost{
+					VirtualHost: VirtualHost{
+						Host: "example.com",
+						routes: []*Route{{
+							Prefix:   "/",
+							Object:   i1,
+							Backends: []Backend{{Service: kuard}},
+						}},
+					},
+					MinProtoVersion: auth.TlsParameters_TLSv1_1,
+					Secret:          &Secret{Object: sec},
+					ClientValidation: &ClientValidation{
+						CACertificate: &Secret{Object: ca1},
+					},
+				},
+				kuard,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b Builder
+			for _, o := range tc.objs {
+				b.Insert(o)
+			}
+			var got []Vertex
+			b.Build().Visit(func(v Vertex) {
+				got = append(got, v)
+			})
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("want:\n%+v\ngot:\n%+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func rulevalue(path, service string, port intstr.IntOrString) v1beta1.IngressRuleValue {
+	return v1beta1.IngressRuleValue{
+		HTTP: &v1beta1.HTTPIngressRuleValue{
+			Paths: []v1beta1.HTTPIngressPath{{
+				Path: path,
+				Backend: v1beta1.IngressBackend{
+					ServiceName: service,
+					ServicePort: port,
+				},
+			}},
+		},
+	}
+}
+
+// backendService returns a Service vertex for a service with a single
+// TCP port.
+func backendService(namespace, name string, port int) *Service {
+	s := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Protocol:   "TCP",
+				Port:       int32(port),
+				TargetPort: intstr.FromInt(port),
+			}},
+		},
+	}
+	return &Service{
+		Namespace:   namespace,
+		Name:        name,
+		Port:        strconv.Itoa(port),
+		Object:      s,
+		ServicePort: &s.Spec.Ports[0],
+	}
+}
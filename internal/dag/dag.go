@@ -0,0 +1,333 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dag provides a data model, in the form of a directed acyclic graph,
+// of the relationship between Kubernetes Ingress, IngressRoute, Service, and
+// Secret objects.
+package dag
+
+import (
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
+	"k8s.io/api/core/v1"
+)
+
+// A DAG represents a directed acyclic graph of objects representing the
+// relationship between Kubernetes Ingress and IngressRoute objects, the
+// backend Services, and Secret objects. A DAG is immutable once built.
+type DAG struct {
+	roots    []Vertex
+	statuses []Status
+}
+
+// Visit calls f for every root of this DAG. VirtualHosts are visited
+// first, then SecureVirtualHosts, then Services. Within each kind roots
+// are visited in a stable order.
+func (d *DAG) Visit(f func(Vertex)) {
+	for _, r := range d.roots {
+		f(r)
+	}
+}
+
+// Statuses returns the status of every IngressRoute from
+// which this DAG was built, in namespace, name order.
+func (d *DAG) Statuses() []Status {
+	return d.statuses
+}
+
+// IngressRoute statuses.
+const (
+	StatusValid    = "valid"
+	StatusInvalid  = "invalid"
+	StatusOrphaned = "orphaned"
+)
+
+// A Status is the status of an IngressRoute found while building a
+// DAG. Only valid IngressRoutes contribute routes to the DAG.
+type Status struct {
+	Object *ingressroutev1.IngressRoute
+
+	// Status is StatusValid, StatusInvalid, or StatusOrphaned.
+	Status string
+
+	// Description describes the reason for Status.
+	Description string
+}
+
+// A Vertex is a node in the DAG.
+type Vertex interface {
+	// Visit calls f for each child of this Vertex.
+	Visit(f func(Vertex))
+}
+
+// A VirtualHost represents a named L7 virtual host served by the
+// insecure (HTTP) listener.
+type VirtualHost struct {
+	// Host is the fully qualified domain name of this virtual host,
+	// or "*" for the default virtual host.
+	Host string
+
+	// Aliases are the additional domain names served by this
+	// virtual host.
+	Aliases []string
+
+	// HeadersPolicy, if not nil, manages the headers of every
+	// request to, and response from, this virtual host.
+	HeadersPolicy *HeadersPolicy
+
+	routes []*Route
+}
+
+// Routes returns the routes of this virtual host in the order
+// in which they were added.
+func (v *VirtualHost) Routes() []*Route { return v.routes }
+
+func (v *VirtualHost) Visit(f func(Vertex)) {
+	for _, r := range v.routes {
+		f(r)
+	}
+}
+
+// addAlias adds alias to the aliases of v, if it is not already present.
+func (v *VirtualHost) addAlias(alias string) {
+	if alias == v.Host {
+		return
+	}
+	for _, a := range v.Aliases {
+		if a == alias {
+			return
+		}
+	}
+	v.Aliases = append(v.Aliases, alias)
+}
+
+func (v *VirtualHost) addRoute(r *Route) {
+	v.routes = append(v.routes, r)
+}
+
+// A SecureVirtualHost represents a named L7 virtual host served by
+// the secure (HTTPS) listener.
+type SecureVirtualHost struct {
+	VirtualHost
+
+	// MinProtoVersion is the minimum TLS protocol version accepted
+	// by this virtual host.
+	MinProtoVersion auth.TlsParameters_TlsProtocol
+
+	// Secret holds the certificate and private key presented by this
+	// virtual host. Secret is nil if the secret named by the virtual
+	// host does not exist, or is not a valid TLS secret.
+	Secret *Secret
+
+	// ClientValidation, if not nil, requires clients of this
+	// virtual host to present a certificate it verifies.
+	ClientValidation *ClientValidation
+}
+
+func (s *SecureVirtualHost) Visit(f func(Vertex)) {
+	s.VirtualHost.Visit(f)
+	if s.Secret != nil {
+		f(s.Secret)
+	}
+	if cv := s.ClientValidation; cv != nil && cv.CACertificate != nil {
+		f(cv.CACertificate)
+	}
+}
+
+// ClientValidation describes how to verify the certificate
+// presented by the clients of a SecureVirtualHost.
+type ClientValidation struct {
+	// CACertificate holds the CA certificate used to verify client
+	// certificates. CACertificate is nil if the secret named does
+	// not exist, or does not contain a CA certificate.
+	CACertificate *Secret
+
+	// ForwardClientCertificate forwards the details of the client
+	// certificate to the backend.
+	ForwardClientCertificate bool
+}
+
+// A Route represents a path match, and the Services to which
+// requests matching that path are forwarded.
+type Route struct {
+	// Prefix is the path prefix matched by this route.
+	// Prefix is empty if the route matches Exact or Regex.
+	Prefix string
+
+	// Exact is the path matched exactly by this route.
+	Exact string
+
+	// Regex is the regular expression matched by this route.
+	Regex string
+
+	// Headers are the conditions on request headers, all of
+	// which must be met for this route to match.
+	Headers []HeaderCondition
+
+	// PrefixRewrite, if set, replaces the matched Prefix, or Exact
+	// path, of the request before it is forwarded.
+	PrefixRewrite string
+
+	// Object is the *v1beta1.Ingress or *ingressroutev1.IngressRoute
+	// which defines this route.
+	Object interface{}
+
+	// Websocket enables websocket upgrades for this route.
+	Websocket bool
+
+	// HTTPSUpgrade redirects requests for this route
+	// to the secure listener.
+	HTTPSUpgrade bool
+
+	// Backends are the Services to which requests are forwarded.
+	Backends []Backend
+
+	// Redirect, if not nil, redirects requests rather than
+	// forwarding them. A Route which redirects has no Backends.
+	Redirect *Redirect
+}
+
+func (r *Route) Visit(f func(Vertex)) {
+	for _, b := range r.Backends {
+		f(b.Service)
+	}
+}
+
+// A Redirect describes an HTTP redirect. The parts of the request
+// URL which are not replaced by the Redirect are kept.
+type Redirect struct {
+	// Host, if set, replaces the host of the URL.
+	Host string
+
+	// Path, if set, replaces the path of the URL.
+	Path string
+
+	// HTTPS replaces the scheme of the URL with https.
+	HTTPS bool
+
+	// StatusCode is 301, 302, 307, or 308.
+	StatusCode int
+}
+
+// Header match types.
+const (
+	HeaderMatchTypeExact   = "exact"
+	HeaderMatchTypeRegex   = "regex"
+	HeaderMatchTypePresent = "present"
+)
+
+// A HeaderCondition matches a request header.
+type HeaderCondition struct {
+	// Name is the name of the header.
+	Name string
+
+	// MatchType is HeaderMatchTypeExact, HeaderMatchTypeRegex,
+	// or HeaderMatchTypePresent.
+	MatchType string
+
+	// Value is the exact value or regular expression matched.
+	// Value is empty for HeaderMatchTypePresent.
+	Value string
+
+	// Invert inverts the condition.
+	Invert bool
+}
+
+// HeadersPolicy describes how the headers of requests
+// and responses are managed.
+type HeadersPolicy struct {
+	// RequestHeadersToAdd are added to requests.
+	RequestHeadersToAdd []HeaderValue
+
+	// ResponseHeadersToAdd are added to responses.
+	ResponseHeadersToAdd []HeaderValue
+
+	// ResponseHeadersToRemove are removed from responses.
+	ResponseHeadersToRemove []string
+}
+
+// orNil returns p, or nil if p manages no headers.
+func (p *HeadersPolicy) orNil() *HeadersPolicy {
+	if len(p.RequestHeadersToAdd)+len(p.ResponseHeadersToAdd)+len(p.ResponseHeadersToRemove) == 0 {
+		return nil
+	}
+	return p
+}
+
+// A HeaderValue is a header name and value.
+type HeaderValue struct {
+	Name, Value string
+}
+
+// A Backend is a weighted edge from a Route to a Service.
+type Backend struct {
+	*Service
+
+	// Weight is the share of requests forwarded to Service.
+	// If nil, the remaining share is divided evenly between
+	// the Backends without a weight.
+	Weight *int
+}
+
+// A Service represents a single port of a Kubernetes Service.
+// A Service port exposed by name is represented twice, once by its
+// name and once by its number.
+type Service struct {
+	// Namespace and Name identify the Kubernetes Service.
+	Namespace, Name string
+
+	// Port is the name or number of the service port.
+	Port string
+
+	// Object is the Kubernetes Service. Object is nil if a Route
+	// refers to a Service, or a service port, which does not exist.
+	Object *v1.Service
+
+	// ServicePort is the port of Object identified by Port.
+	// ServicePort is nil if Object is nil.
+	ServicePort *v1.ServicePort
+
+	// UpstreamValidation, if not nil, describes how the certificate
+	// presented by the Service's endpoints is verified.
+	UpstreamValidation *UpstreamValidation
+}
+
+func (s *Service) Visit(f func(Vertex)) {
+	if uv := s.UpstreamValidation; uv != nil && uv.CACertificate != nil {
+		f(uv.CACertificate)
+	}
+}
+
+// UpstreamValidation describes how to verify the certificate
+// presented by a Service's endpoints.
+type UpstreamValidation struct {
+	// CACertificate holds the CA certificate used to verify the
+	// endpoint's certificate. CACertificate is nil if the secret
+	// named does not exist, or does not contain a CA certificate.
+	CACertificate *Secret
+
+	// SubjectName is the name expected in the subjectAltName of
+	// the endpoint's certificate, and sent as its SNI name.
+	SubjectName string
+}
+
+// A Secret represents a Kubernetes Secret holding a TLS certificate
+// and private key, or a CA certificate.
+type Secret struct {
+	Object *v1.Secret
+}
+
+func (s *Secret) Visit(func(Vertex)) {}
+
+// CACertificateKey is the key of the CA certificate in a Secret.
+const CACertificateKey = "ca.crt"
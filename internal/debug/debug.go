@@ -14,13 +14,10 @@
 package debug
 
 import (
-	"context"
-	"fmt"
 	"net/http"
 	"net/http/pprof"
-	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/heptio/contour/internal/httpsvc"
 )
 
 // contour debugging services
@@ -28,23 +25,16 @@ import (
 // debugService serves various debugging endpoints including
 // /debug/pprof.
 type Service struct {
-	Addr string
-	Port int
+	httpsvc.Service
 
-	logrus.FieldLogger
+	// LoadStats, if set, is served at /debug/lrs.
+	LoadStats http.Handler
 }
 
 // Start fulfills the g.Start contract.
 // When stop is closed the http server will shutdown.
-func (svc *Service) Start(stop <-chan struct{}) (err error) {
-	defer func() {
-		if err != nil {
-			svc.WithError(err).Error("terminated with error")
-		} else {
-			svc.Info("stopped")
-		}
-	}()
-	mux := http.NewServeMux()
+func (svc *Service) Start(stop <-chan struct{}) error {
+	mux := &svc.ServeMux
 
 	// register the /debug/pprof handlers on this mux.
 	mux.HandleFunc("/debug/pprof/", pprof.Index)
@@ -53,25 +43,9 @@ func (svc *Service) Start(stop <-chan struct{}) (err error) {
 	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 
-	s := http.Server{
-		Addr:           fmt.Sprintf("%s:%d", svc.Addr, svc.Port),
-		Handler:        mux,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   5 * time.Minute, // allow for long trace requests
-		MaxHeaderBytes: 1 << 11,         // 8kb should be enough for anyone
+	if svc.LoadStats != nil {
+		mux.Handle("/debug/lrs", svc.LoadStats)
 	}
 
-	go func() {
-		// wait for stop signal from group.
-		<-stop
-
-		// shutdown the server with 5 seconds grace.
-		ctx := context.Background()
-		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		defer cancel()
-		s.Shutdown(ctx)
-	}()
-
-	svc.WithField("address", s.Addr).Info("started")
-	return s.ListenAndServe()
+	return svc.Service.Start(stop)
 }
@@ -64,7 +64,7 @@ func setup(t *testing.T) (cache.ResourceEventHandler, *grpc.ClientConn, func())
 	check(t, err)
 	var wg sync.WaitGroup
 	wg.Add(1)
-	srv := cgrpc.NewAPI(log, tr, et)
+	srv := cgrpc.NewAPI(log, tr, et, nil, nil, nil)
 	go func() {
 		defer wg.Done()
 		srv.Serve(l)
@@ -57,6 +57,16 @@ type ConfigWriter struct {
 	// Defaults to 8001.
 	XDSGRPCPort int
 
+	// ADS configures Envoy to retrieve all resources over a single
+	// stream to the aggregated discovery service.
+	// Defaults to false.
+	ADS bool
+
+	// LoadReporting configures Envoy to send load reports
+	// to the load reporting service.
+	// Defaults to false.
+	LoadReporting bool
+
 	// StatsdEnabled enables metrics output via statsd
 	// Defaults to false.
 	StatsdEnabled bool
@@ -71,7 +81,17 @@ type ConfigWriter struct {
 }
 
 const yamlConfig = `dynamic_resources:
+{{ if .ADS }}  ads_config:
+    api_type: GRPC
+    cluster_names: [contour]
+    grpc_services:
+    - envoy_grpc:
+        cluster_name: contour
   lds_config:
+    ads: {}
+  cds_config:
+    ads: {}
+{{ else }}  lds_config:
     api_config_source:
       api_type: GRPC
       cluster_names: [contour]
@@ -85,6 +105,7 @@ const yamlConfig = `dynamic_resources:
       grpc_services:
       - envoy_grpc:
           cluster_name: contour
+{{ end -}}
 static_resources:
   clusters:
   - name: contour
@@ -151,6 +172,14 @@ stats_sinks:
           address: {{ if .StatsdAddress }}{{ .StatsdAddress }}{{ else }}127.0.0.1{{ end }}
           port_value: {{ if .StatsdPort }}{{ .StatsdPort }}{{ else }}9125{{ end }}
 {{ end -}}
+{{ if .LoadReporting }}cluster_manager:
+  load_stats_config:
+    api_type: GRPC
+    cluster_names: [contour]
+    grpc_services:
+    - envoy_grpc:
+        cluster_name: contour
+{{ end -}}
 admin:
   access_log_path: {{ if .AdminAccessLogPath }}{{ .AdminAccessLogPath }}{{ else }}/dev/null{{ end }}
   address:
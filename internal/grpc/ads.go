@@ -0,0 +1,229 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2"
+)
+
+// adsOrder is the order in which responses of each type are sent
+// when more than one type has changed. Clusters are sent before the
+// endpoints which populate them, and listeners before the routes
+// they reference, so Envoy never refers to a resource it has not
+// yet received.
+var adsOrder = map[string]int{
+	clusterType:  0,
+	endpointType: 1,
+	listenerType: 2,
+	routeType:    3,
+}
+
+// adsType holds the state of a single resource type
+// on an aggregated discovery service stream.
+type adsType struct {
+	resource
+
+	req   *v2.DiscoveryRequest // the most recent request for this type
+	last  int                  // the version of the cache last sent
+	nonce string               // the nonce of the last response sent
+	hash  uint64               // the hash of the resources last sent
+	sent  bool                 // a response has been sent for req's resource names
+	gen   int                  // generation of the outstanding registration
+}
+
+// adsUpdate records a change to the cache of a resource type.
+type adsUpdate struct {
+	typeURL string
+	gen     int
+	version int
+}
+
+// ads processes an aggregated discovery service stream of DiscoveryRequests
+// for any registered resource type.
+//
+// Unlike stream, requests and responses for each type are interleaved on one
+// stream, so ads receives requests in a separate goroutine while waiting for
+// any type's cache to change. Changes to more than one type are sent in
+// adsOrder.
+func (xh *xdsHandler) ads(st grpcStream) (err error) {
+	// bump connection counter and set it as a field on the logger
+	log := xh.WithField("connection", xh.connections.next()).WithField("ads", true)
+
+	types := make(map[string]*adsType)
+	defer func() {
+		for typeURL := range types {
+			xh.metrics.StreamClosed(typeURL)
+		}
+		if err != nil {
+			log.WithError(err).Error("stream terminated")
+		} else {
+			log.Info("stream terminated")
+		}
+	}()
+
+	ctx := st.Context()
+
+	// hold back the first response until the caches are complete.
+	if err := xh.waitReady(ctx, log); err != nil {
+		return err
+	}
+
+	reqs := make(chan *v2.DiscoveryRequest)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			req, err := st.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// register registers for the next change to t's cache after version last.
+	// Each registration uses a new channel, the outstanding registration, if
+	// any, is superseded and its update will be discarded.
+	updates := make(chan adsUpdate)
+	register := func(t *adsType, last int) {
+		t.gen++
+		ch := make(chan int, 1)
+		go func(typeURL string, gen int) {
+			select {
+			case v := <-ch:
+				select {
+				case updates <- adsUpdate{typeURL: typeURL, gen: gen, version: v}:
+				case <-ctx.Done():
+				}
+			case <-ctx.Done():
+			}
+		}(t.TypeURL(), t.gen)
+		t.Register(ch, last)
+	}
+
+	var nonce counter
+	send := func(t *adsType, version int) error {
+		t.last = version
+		resources, err := toAny(t, toFilter(t.req.ResourceNames))
+		if err != nil {
+			return err
+		}
+		h := hashAny(resources)
+		if t.sent && h == t.hash {
+			log.WithField("type_url", t.TypeURL()).WithField("version", version).Debug("unchanged")
+			return nil
+		}
+		resp := &v2.DiscoveryResponse{
+			VersionInfo: strconv.Itoa(version),
+			Resources:   resources,
+			TypeUrl:     t.TypeURL(),
+			Nonce:       strconv.FormatUint(nonce.next(), 10),
+		}
+		start := time.Now()
+		if err := st.Send(resp); err != nil {
+			return err
+		}
+		xh.metrics.ObserveSend(resp.TypeUrl, time.Since(start))
+		t.nonce, t.hash, t.sent = resp.Nonce, h, true
+		log.WithField("type_url", resp.TypeUrl).WithField("count", len(resources)).WithField("version", resp.VersionInfo).WithField("nonce", resp.Nonce).Info("response")
+		return nil
+	}
+
+	for {
+		select {
+		case req := <-reqs:
+			t, ok := types[req.TypeUrl]
+			if !ok {
+				r, ok := xh.resources[req.TypeUrl]
+				if !ok {
+					return fmt.Errorf("no resource registered for typeURL %q", req.TypeUrl)
+				}
+				t = &adsType{resource: r}
+				types[req.TypeUrl] = t
+				xh.metrics.StreamOpened(req.TypeUrl)
+			}
+
+			log := log.WithField("version_info", req.VersionInfo).WithField("resource_names", req.ResourceNames).WithField("type_url", req.TypeUrl).WithField("response_nonce", req.ResponseNonce).WithField("error_detail", req.ErrorDetail)
+			if !xh.reply(log, req, t.nonce, t.last) {
+				continue
+			}
+
+			subscribed := t.req == nil || !equalNames(t.req.ResourceNames, req.ResourceNames)
+			t.req = req
+			if subscribed {
+				// a new set of resource names, respond with
+				// the current contents of the cache.
+				t.sent = false
+				register(t, 0)
+			}
+		case u := <-updates:
+			// collect any other pending updates so they
+			// can be sent in order.
+			pending := []adsUpdate{u}
+		drain:
+			for {
+				select {
+				case u := <-updates:
+					pending = append(pending, u)
+				default:
+					break drain
+				}
+			}
+			sort.SliceStable(pending, func(i, j int) bool {
+				return adsOrder[pending[i].typeURL] < adsOrder[pending[j].typeURL]
+			})
+			for _, u := range pending {
+				t := types[u.typeURL]
+				if u.gen != t.gen {
+					// superseded by a later registration.
+					continue
+				}
+				if err := send(t, u.version); err != nil {
+					return err
+				}
+				register(t, t.last)
+			}
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// equalNames returns true if a and b contain the same resource names.
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	m := make(map[string]bool, len(a))
+	for _, n := range a {
+		m[n] = true
+	}
+	for _, n := range b {
+		if !m[n] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/gogo/protobuf/proto"
+)
+
+func TestXDSHandlerADS(t *testing.T) {
+	potato := func(typeurl string) resource {
+		return &mockResource{
+			register: func(ch chan int, last int) {
+				// the cache is at version 1 and never changes.
+				if last < 1 {
+					ch <- 1
+				}
+			},
+			values: func(fn func(string) bool) []proto.Message {
+				return []proto.Message{new(v2.ClusterLoadAssignment)}
+			},
+			typeurl: func() string { return typeurl },
+		}
+	}
+
+	tests := map[string]struct {
+		reqs []*v2.DiscoveryRequest
+		want []string // type urls of the responses sent
+		err  error
+	}{
+		"no registered typeURL": {
+			reqs: []*v2.DiscoveryRequest{{
+				TypeUrl: "com.heptio.potato",
+			}},
+			err: fmt.Errorf("no resource registered for typeURL %q", "com.heptio.potato"),
+		},
+		"one response per type": {
+			reqs: []*v2.DiscoveryRequest{{
+				TypeUrl: clusterType,
+			}, {
+				TypeUrl: listenerType,
+			}},
+			want: []string{clusterType, listenerType},
+			err:  context.Canceled,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			xh := xdsHandler{
+				FieldLogger: testLogger(t),
+				resources: map[string]resource{
+					clusterType:  potato(clusterType),
+					listenerType: potato(listenerType),
+				},
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var mu sync.Mutex
+			var got []string
+			reqs := tc.reqs
+			st := &mockStream{
+				context: func() context.Context { return ctx },
+				recv: func() (*v2.DiscoveryRequest, error) {
+					if len(reqs) == 0 {
+						<-ctx.Done()
+						return nil, ctx.Err()
+					}
+					req := reqs[0]
+					reqs = reqs[1:]
+					return req, nil
+				},
+				send: func(resp *v2.DiscoveryResponse) error {
+					mu.Lock()
+					defer mu.Unlock()
+					got = append(got, resp.TypeUrl)
+					if len(got) == len(tc.want) {
+						cancel()
+					}
+					return nil
+				},
+			}
+
+			err := xh.ads(st)
+			if !reflect.DeepEqual(tc.err, err) {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			sort.Strings(got)
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("expected: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEqualNames(t *testing.T) {
+	tests := map[string]struct {
+		a, b []string
+		want bool
+	}{
+		"both empty": {
+			a: nil, b: []string{}, want: true,
+		},
+		"same order": {
+			a: []string{"a", "b"}, b: []string{"a", "b"}, want: true,
+		},
+		"different order": {
+			a: []string{"a", "b"}, b: []string{"b", "a"}, want: true,
+		},
+		"different length": {
+			a: []string{"a"}, b: []string{"a", "b"}, want: false,
+		},
+		"different names": {
+			a: []string{"a", "b"}, b: []string{"a", "c"}, want: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := equalNames(tc.a, tc.b)
+			if tc.want != got {
+				t.Fatalf("expected: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
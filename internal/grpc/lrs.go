@@ -0,0 +1,329 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_service_v2 "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v2"
+	"github.com/gogo/protobuf/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// loadReportingInterval is the interval at which Envoy
+// is asked to send load reports.
+const loadReportingInterval = 10 * time.Second
+
+// LoadStats aggregates the load reports received from Envoy over the
+// load reporting service by cluster and locality.
+//
+// LoadStats implements http.Handler, serving the current request and
+// error rate of each upstream as JSON, and prometheus.Collector.
+type LoadStats struct {
+	mu sync.Mutex
+
+	// nodes holds the most recent load report from
+	// each stream, indexed by connection.
+	nodes map[uint64]map[upstream]upstreamLoad
+
+	// totals holds the total requests and errors
+	// reported for each upstream.
+	totals map[upstream]upstreamLoad
+}
+
+// upstream identifies a cluster in a locality.
+type upstream struct {
+	cluster, region, zone, subzone string
+}
+
+// upstreamLoad is the load on an upstream reported over interval.
+type upstreamLoad struct {
+	success, errors, inProgress uint64
+	interval                    time.Duration
+}
+
+// UpstreamLoad is the load on an upstream, summed across
+// the most recent load report from each Envoy.
+type UpstreamLoad struct {
+	Cluster     string  `json:"cluster"`
+	Region      string  `json:"region,omitempty"`
+	Zone        string  `json:"zone,omitempty"`
+	SubZone     string  `json:"sub_zone,omitempty"`
+	RequestRate float64 `json:"request_rate"`
+	ErrorRate   float64 `json:"error_rate"`
+	InProgress  uint64  `json:"in_progress"`
+}
+
+// report records req as the most recent report from conn. The load
+// in req covers interval, the time since conn's previous report.
+func (ls *LoadStats) report(conn uint64, req *envoy_service_v2.LoadStatsRequest, interval time.Duration) {
+	loads := make(map[upstream]upstreamLoad)
+	for _, cs := range req.ClusterStats {
+		for _, uls := range cs.UpstreamLocalityStats {
+			l := uls.Locality
+			u := upstream{
+				cluster: cs.ClusterName,
+				region:  l.GetRegion(),
+				zone:    l.GetZone(),
+				subzone: l.GetSubZone(),
+			}
+			load := loads[u]
+			load.success += uls.TotalSuccessfulRequests
+			load.errors += uls.TotalErrorRequests
+			load.inProgress += uls.TotalRequestsInProgress
+			load.interval = interval
+			loads[u] = load
+		}
+	}
+	ls.record(conn, loads)
+}
+
+// record records loads as the most recent report from conn.
+func (ls *LoadStats) record(conn uint64, loads map[upstream]upstreamLoad) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.nodes == nil {
+		ls.nodes = make(map[uint64]map[upstream]upstreamLoad)
+		ls.totals = make(map[upstream]upstreamLoad)
+	}
+	ls.nodes[conn] = loads
+	for u, load := range loads {
+		total := ls.totals[u]
+		total.success += load.success
+		total.errors += load.errors
+		ls.totals[u] = total
+	}
+}
+
+// remove forgets the most recent load report from conn.
+func (ls *LoadStats) remove(conn uint64) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	delete(ls.nodes, conn)
+}
+
+// Upstreams returns the current load on each upstream,
+// sorted by cluster and locality.
+func (ls *LoadStats) Upstreams() []UpstreamLoad {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	m := make(map[upstream]*UpstreamLoad)
+	for _, loads := range ls.nodes {
+		for u, load := range loads {
+			ul, ok := m[u]
+			if !ok {
+				ul = &UpstreamLoad{
+					Cluster: u.cluster,
+					Region:  u.region,
+					Zone:    u.zone,
+					SubZone: u.subzone,
+				}
+				m[u] = ul
+			}
+			if secs := load.interval.Seconds(); secs > 0 {
+				ul.RequestRate += float64(load.success+load.errors) / secs
+				ul.ErrorRate += float64(load.errors) / secs
+			}
+			ul.InProgress += load.inProgress
+		}
+	}
+
+	upstreams := make([]UpstreamLoad, 0, len(m))
+	for _, ul := range m {
+		upstreams = append(upstreams, *ul)
+	}
+	sort.Slice(upstreams, func(i, j int) bool {
+		a, b := upstreams[i], upstreams[j]
+		switch {
+		case a.Cluster != b.Cluster:
+			return a.Cluster < b.Cluster
+		case a.Region != b.Region:
+			return a.Region < b.Region
+		case a.Zone != b.Zone:
+			return a.Zone < b.Zone
+		default:
+			return a.SubZone < b.SubZone
+		}
+	})
+	return upstreams
+}
+
+// ServeHTTP writes the current load on each upstream as JSON.
+func (ls *LoadStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(ls.Upstreams())
+}
+
+var (
+	upstreamLabels = []string{"cluster", "region", "zone", "sub_zone"}
+
+	upstreamRequestsDesc = prometheus.NewDesc(
+		"contour_upstream_requests_total",
+		"Total requests to an upstream cluster and locality reported by Envoy.",
+		upstreamLabels, nil,
+	)
+	upstreamErrorsDesc = prometheus.NewDesc(
+		"contour_upstream_errors_total",
+		"Total error responses from an upstream cluster and locality reported by Envoy.",
+		upstreamLabels, nil,
+	)
+	upstreamRequestRateDesc = prometheus.NewDesc(
+		"contour_upstream_request_rate",
+		"Requests per second to an upstream cluster and locality over the most recent load reports.",
+		upstreamLabels, nil,
+	)
+	upstreamErrorRateDesc = prometheus.NewDesc(
+		"contour_upstream_error_rate",
+		"Error responses per second from an upstream cluster and locality over the most recent load reports.",
+		upstreamLabels, nil,
+	)
+)
+
+// Describe implements prometheus.Collector.
+func (ls *LoadStats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upstreamRequestsDesc
+	ch <- upstreamErrorsDesc
+	ch <- upstreamRequestRateDesc
+	ch <- upstreamErrorRateDesc
+}
+
+// Collect implements prometheus.Collector.
+func (ls *LoadStats) Collect(ch chan<- prometheus.Metric) {
+	for _, ul := range ls.Upstreams() {
+		labels := []string{ul.Cluster, ul.Region, ul.Zone, ul.SubZone}
+		ch <- prometheus.MustNewConstMetric(upstreamRequestRateDesc, prometheus.GaugeValue, ul.RequestRate, labels...)
+		ch <- prometheus.MustNewConstMetric(upstreamErrorRateDesc, prometheus.GaugeValue, ul.ErrorRate, labels...)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for u, total := range ls.totals {
+		labels := []string{u.cluster, u.region, u.zone, u.subzone}
+		ch <- prometheus.MustNewConstMetric(upstreamRequestsDesc, prometheus.CounterValue, float64(total.success+total.errors), labels...)
+		ch <- prometheus.MustNewConstMetric(upstreamErrorsDesc, prometheus.CounterValue, float64(total.errors), labels...)
+	}
+}
+
+type lrsStream interface {
+	Context() context.Context
+	Send(*envoy_service_v2.LoadStatsResponse) error
+	Recv() (*envoy_service_v2.LoadStatsRequest, error)
+}
+
+// loadstats processes a stream of LoadStatsRequests, recording each
+// load report in ls. Envoy is asked to report on every cluster in the
+// CDS cache, and is asked again each time the set of clusters changes.
+func (xh *xdsHandler) loadstats(st lrsStream, ls *LoadStats) (err error) {
+	// bump connection counter and set it as a field on the logger
+	conn := xh.connections.next()
+	log := xh.WithField("connection", conn).WithField("type_url", "lrs")
+
+	defer func() {
+		ls.remove(conn)
+		if err != nil {
+			log.WithError(err).Error("stream terminated")
+		} else {
+			log.Info("stream terminated")
+		}
+	}()
+
+	cds, ok := xh.resources[clusterType]
+	if !ok {
+		return fmt.Errorf("no resource registered for typeURL %q", clusterType)
+	}
+
+	// the first request identifies the node, it carries no load report.
+	req, err := st.Recv()
+	if err != nil {
+		return err
+	}
+	log = log.WithField("node", req.Node.GetId())
+	log.Info("stream_start")
+
+	ctx := st.Context()
+	reqs := make(chan *envoy_service_v2.LoadStatsRequest)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			req, err := st.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// reported is the start of the period covered by Envoy's next
+	// load report. Envoy starts a new period each time it receives
+	// a LoadStatsResponse, and each time it sends a report.
+	var reported time.Time
+
+	ch := make(chan int, 1)
+	last := 0
+	cds.Register(ch, last)
+	for {
+		select {
+		case last = <-ch:
+			// the set of clusters may have changed, ask Envoy to
+			// report on the current set.
+			if names := clusterNames(cds); len(names) > 0 {
+				resp := &envoy_service_v2.LoadStatsResponse{
+					Clusters:              names,
+					LoadReportingInterval: types.DurationProto(loadReportingInterval),
+				}
+				if err := st.Send(resp); err != nil {
+					return err
+				}
+				reported = time.Now()
+				log.WithField("count", len(names)).Info("response")
+			}
+			cds.Register(ch, last)
+		case req := <-reqs:
+			now := time.Now()
+			if !reported.IsZero() {
+				ls.report(conn, req, now.Sub(reported))
+			}
+			reported = now
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// clusterNames returns the names of the clusters in r.
+func clusterNames(r resource) []string {
+	var names []string
+	for _, v := range r.Values(func(string) bool { return true }) {
+		if c, ok := v.(*v2.Cluster); ok {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,176 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	envoy_service_v2 "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v2"
+	"github.com/gogo/protobuf/jsonpb"
+)
+
+func TestLoadStatsUpstreams(t *testing.T) {
+	kuard := upstream{cluster: "default/kuard/80", zone: "us-east-1a"}
+	kuardb := upstream{cluster: "default/kuard/80", zone: "us-east-1b"}
+	httpbin := upstream{cluster: "default/httpbin/80"}
+
+	tests := map[string]struct {
+		reports map[uint64]map[upstream]upstreamLoad
+		remove  []uint64
+		want    []UpstreamLoad
+	}{
+		"empty": {
+			want: []UpstreamLoad{},
+		},
+		"single report": {
+			reports: map[uint64]map[upstream]upstreamLoad{
+				1: {
+					kuard: {success: 90, errors: 10, inProgress: 3, interval: 10 * time.Second},
+				},
+			},
+			want: []UpstreamLoad{{
+				Cluster:     "default/kuard/80",
+				Zone:        "us-east-1a",
+				RequestRate: 10,
+				ErrorRate:   1,
+				InProgress:  3,
+			}},
+		},
+		"summed across nodes": {
+			reports: map[uint64]map[upstream]upstreamLoad{
+				1: {
+					kuard:   {success: 100, interval: 10 * time.Second},
+					httpbin: {success: 5, errors: 5, interval: 10 * time.Second},
+				},
+				2: {
+					kuard:  {success: 40, errors: 10, inProgress: 1, interval: 5 * time.Second},
+					kuardb: {success: 20, interval: 10 * time.Second},
+				},
+			},
+			want: []UpstreamLoad{{
+				Cluster:     "default/httpbin/80",
+				RequestRate: 1,
+				ErrorRate:   0.5,
+			}, {
+				Cluster:     "default/kuard/80",
+				Zone:        "us-east-1a",
+				RequestRate: 20,
+				ErrorRate:   2,
+				InProgress:  1,
+			}, {
+				Cluster:     "default/kuard/80",
+				Zone:        "us-east-1b",
+				RequestRate: 2,
+			}},
+		},
+		"disconnected node": {
+			reports: map[uint64]map[upstream]upstreamLoad{
+				1: {
+					kuard: {success: 100, interval: 10 * time.Second},
+				},
+				2: {
+					kuard: {success: 50, interval: 10 * time.Second},
+				},
+			},
+			remove: []uint64{2},
+			want: []UpstreamLoad{{
+				Cluster:     "default/kuard/80",
+				Zone:        "us-east-1a",
+				RequestRate: 10,
+			}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var ls LoadStats
+			for conn, loads := range tc.reports {
+				ls.record(conn, loads)
+			}
+			for _, conn := range tc.remove {
+				ls.remove(conn)
+			}
+			got := ls.Upstreams()
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("expected: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadStatsTotals(t *testing.T) {
+	kuard := upstream{cluster: "default/kuard/80"}
+	var ls LoadStats
+	ls.record(1, map[upstream]upstreamLoad{kuard: {success: 10, errors: 1}})
+	ls.record(1, map[upstream]upstreamLoad{kuard: {success: 20, errors: 2}})
+	ls.remove(1)
+
+	// totals survive both later reports and disconnection.
+	want := upstreamLoad{success: 30, errors: 3}
+	if got := ls.totals[kuard]; want != got {
+		t.Fatalf("expected: %v, got: %v", want, got)
+	}
+}
+
+func TestLoadStatsReport(t *testing.T) {
+	var req envoy_service_v2.LoadStatsRequest
+	err := jsonpb.UnmarshalString(`{
+		"clusterStats": [{
+			"clusterName": "default/kuard/80",
+			"upstreamLocalityStats": [{
+				"locality": {"zone": "us-east-1a"},
+				"totalSuccessfulRequests": "90",
+				"totalErrorRequests": "10",
+				"totalRequestsInProgress": "3"
+			}, {
+				"locality": {"zone": "us-east-1b"},
+				"totalSuccessfulRequests": "20"
+			}]
+		}, {
+			"clusterName": "default/httpbin/80",
+			"upstreamLocalityStats": [{
+				"totalSuccessfulRequests": "5",
+				"totalErrorRequests": "5"
+			}]
+		}]
+	}`, &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ls LoadStats
+	ls.report(1, &req, 10*time.Second)
+
+	want := []UpstreamLoad{{
+		Cluster:     "default/httpbin/80",
+		RequestRate: 1,
+		ErrorRate:   0.5,
+	}, {
+		Cluster:     "default/kuard/80",
+		Zone:        "us-east-1a",
+		RequestRate: 10,
+		ErrorRate:   1,
+		InProgress:  3,
+	}, {
+		Cluster:     "default/kuard/80",
+		Zone:        "us-east-1b",
+		RequestRate: 2,
+	}}
+	got := ls.Upstreams()
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("expected: %v, got: %v", want, got)
+	}
+}
@@ -110,7 +110,10 @@ func (l listenersByName) Less(i, j int) bool {
 
 // RDS implements the RDS v2 gRPC API.
 type RDS struct {
-	HTTP, HTTPS interface {
+	// HTTP and HTTPS hold the virtual hosts of the ingress_http and
+	// ingress_https route configurations, ClientValidated the secure
+	// virtual hosts each served by a route configuration of its own.
+	HTTP, HTTPS, ClientValidated interface {
 		// Values returns a slice of proto.Message implementations that match
 		// the provided filter.
 		Values(func(string) bool) []proto.Message
@@ -144,6 +147,15 @@ func (r *RDS) Values(filter func(string) bool) []proto.Message {
 			VirtualHosts: toRouteVirtualHosts(r.HTTPS.Values(matchAll)),
 		})
 	}
+	for _, m := range r.ClientValidated.Values(matchAll) {
+		vh := m.(*route.VirtualHost)
+		if name := contour.ClientValidatedRouteName(vh.Name); filter(name) {
+			v = append(v, &v2.RouteConfiguration{
+				Name:         name,
+				VirtualHosts: []route.VirtualHost{*vh},
+			})
+		}
+	}
 	return v
 }
 
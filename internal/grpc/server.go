@@ -20,10 +20,12 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
 	envoy_service_v2 "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v2"
 	"github.com/sirupsen/logrus"
 
 	"github.com/heptio/contour/internal/contour"
+	"github.com/heptio/contour/internal/metrics"
 )
 
 const (
@@ -32,7 +34,11 @@ const (
 )
 
 // NewAPI returns a *grpc.Server which responds to the Envoy v2 xDS gRPC API.
-func NewAPI(log logrus.FieldLogger, t *contour.Translator, endpoints cache) *grpc.Server {
+// If lrs is not nil, load reports received over the load reporting service
+// are recorded in lrs. If m is not nil, the activity of xDS streams is
+// recorded in m. If ready is not nil, no xDS responses are sent until
+// ready is closed.
+func NewAPI(log logrus.FieldLogger, t *contour.Translator, endpoints cache, lrs *LoadStats, m *metrics.Metrics, ready <-chan struct{}) *grpc.Server {
 	opts := []grpc.ServerOption{
 		// By default the Go grpc library defaults to a value of ~100 streams per
 		// connection. This number is likely derived from the HTTP/2 spec:
@@ -46,6 +52,8 @@ func NewAPI(log logrus.FieldLogger, t *contour.Translator, endpoints cache) *grp
 	s := &grpcServer{
 		xdsHandler{
 			FieldLogger: log,
+			metrics:     m,
+			ready:       ready,
 			resources: map[string]resource{
 				clusterType: &CDS{
 					cache: &t.ClusterCache,
@@ -57,24 +65,31 @@ func NewAPI(log logrus.FieldLogger, t *contour.Translator, endpoints cache) *grp
 					cache: &t.ListenerCache,
 				},
 				routeType: &RDS{
-					HTTP:  &t.VirtualHostCache.HTTP,
-					HTTPS: &t.VirtualHostCache.HTTPS,
-					Cond:  &t.VirtualHostCache.Cond,
+					HTTP:            &t.VirtualHostCache.HTTP,
+					HTTPS:           &t.VirtualHostCache.HTTPS,
+					ClientValidated: &t.VirtualHostCache.ClientValidated,
+					Cond:            &t.VirtualHostCache.Cond,
 				},
 			},
 		},
+		lrs,
 	}
 
 	v2.RegisterClusterDiscoveryServiceServer(g, s)
 	v2.RegisterEndpointDiscoveryServiceServer(g, s)
 	v2.RegisterListenerDiscoveryServiceServer(g, s)
 	v2.RegisterRouteDiscoveryServiceServer(g, s)
+	discovery.RegisterAggregatedDiscoveryServiceServer(g, s)
+	envoy_service_v2.RegisterLoadReportingServiceServer(g, s)
 	return g
 }
 
-// grpcServer implements the LDS, RDS, CDS, and EDS, gRPC endpoints.
+// grpcServer implements the LDS, RDS, CDS, EDS, ADS, and LRS gRPC endpoints.
 type grpcServer struct {
 	xdsHandler
+
+	// lrs, if set, records load reports from Envoy.
+	lrs *LoadStats
 }
 
 // A resource provides resources formatted as []types.Any.
@@ -110,7 +125,10 @@ func (s *grpcServer) StreamEndpoints(srv v2.EndpointDiscoveryService_StreamEndpo
 }
 
 func (s *grpcServer) StreamLoadStats(srv envoy_service_v2.LoadReportingService_StreamLoadStatsServer) error {
-	return status.Errorf(codes.Unimplemented, "StreamLoadStats Unimplemented")
+	if s.lrs == nil {
+		return status.Errorf(codes.Unimplemented, "StreamLoadStats Unimplemented")
+	}
+	return s.loadstats(srv, s.lrs)
 }
 
 func (s *grpcServer) StreamListeners(srv v2.ListenerDiscoveryService_StreamListenersServer) error {
@@ -120,3 +138,7 @@ func (s *grpcServer) StreamListeners(srv v2.ListenerDiscoveryService_StreamListe
 func (s *grpcServer) StreamRoutes(srv v2.RouteDiscoveryService_StreamRoutesServer) error {
 	return s.stream(srv)
 }
+
+func (s *grpcServer) StreamAggregatedResources(srv discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	return s.ads(srv)
+}
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
 	"github.com/heptio/contour/internal/contour"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
@@ -179,6 +180,36 @@ func TestGRPCStreaming(t *testing.T) {
 			checkrecv(t, stream)          // check we receive one notification
 			checktimeout(t, stream)       // check that the second receive times out
 		},
+		"StreamAggregatedResources": func(t *testing.T) {
+			tr.OnAdd(&v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "simple",
+					Namespace: "default",
+				},
+				Spec: v1.ServiceSpec{
+					Selector: map[string]string{
+						"app": "simple",
+					},
+					Ports: []v1.ServicePort{{
+						Protocol:   "TCP",
+						Port:       80,
+						TargetPort: intstr.FromInt(6502),
+					}},
+				},
+			})
+
+			cc := newClient(t)
+			defer cc.Close()
+			ads := discovery.NewAggregatedDiscoveryServiceClient(cc)
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			stream, err := ads.StreamAggregatedResources(ctx)
+			check(t, err)
+			sendreq(t, stream, clusterType)  // send initial notification
+			checkrecv(t, stream)             // check we receive one notification
+			sendreq(t, stream, listenerType) // subscribe to listeners on the same stream
+			checktimeout(t, stream)          // check that the second receive times out, there is no default listener
+		},
 	}
 
 	log := testLogger(t)
@@ -190,7 +221,7 @@ func TestGRPCStreaming(t *testing.T) {
 			et = &contour.EndpointsTranslator{
 				FieldLogger: log,
 			}
-			srv := NewAPI(log, tr, et)
+			srv := NewAPI(log, tr, et, nil, nil, nil)
 			var err error
 			l, err = net.Listen("tcp", "127.0.0.1:0")
 			check(t, err)
@@ -280,7 +311,7 @@ func TestGRPCFetching(t *testing.T) {
 			et := &contour.EndpointsTranslator{
 				FieldLogger: log,
 			}
-			srv := NewAPI(log, tr, et)
+			srv := NewAPI(log, tr, et, nil, nil, nil)
 			var err error
 			l, err = net.Listen("tcp", "127.0.0.1:0")
 			check(t, err)
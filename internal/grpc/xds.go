@@ -15,14 +15,22 @@ package grpc
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
+	"strconv"
 	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	"github.com/sirupsen/logrus"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
+	"github.com/heptio/contour/internal/metrics"
 )
 
 // xdsHandler implements the Envoy xDS gRPC protocol.
@@ -30,11 +38,43 @@ type xdsHandler struct {
 	logrus.FieldLogger
 	connections counter
 	resources   map[string]resource // registered resource types
+	metrics     *metrics.Metrics
+
+	// ready, if not nil, is closed when the caches hold a complete
+	// view of the cluster. Until then no responses are sent.
+	ready <-chan struct{}
+}
+
+// waitReady blocks until xh is ready to send responses or ctx is done.
+func (xh *xdsHandler) waitReady(ctx context.Context, log logrus.FieldLogger) error {
+	if xh.ready == nil {
+		return nil
+	}
+	select {
+	case <-xh.ready:
+		return nil
+	default:
+	}
+	log.Info("waiting for caches to sync")
+	select {
+	case <-xh.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // fetch handles a single DiscoveryRequest.
 func (xh *xdsHandler) fetch(req *v2.DiscoveryRequest) (*v2.DiscoveryResponse, error) {
-	xh.WithField("connection", xh.connections.next()).WithField("version_info", req.VersionInfo).WithField("resource_names", req.ResourceNames).WithField("type_url", req.TypeUrl).WithField("response_nonce", req.ResponseNonce).WithField("error_detail", req.ErrorDetail).Info("fetch")
+	log := xh.WithField("connection", xh.connections.next()).WithField("version_info", req.VersionInfo).WithField("resource_names", req.ResourceNames).WithField("type_url", req.TypeUrl).WithField("response_nonce", req.ResponseNonce).WithField("error_detail", req.ErrorDetail)
+	log.Info("fetch")
+	if xh.ready != nil {
+		select {
+		case <-xh.ready:
+		default:
+			return nil, status.Error(codes.Unavailable, "caches not synced")
+		}
+	}
 	r, ok := xh.resources[req.TypeUrl]
 	if !ok {
 		return nil, fmt.Errorf("no resource registered for typeURL %q", req.TypeUrl)
@@ -55,13 +95,28 @@ type grpcStream interface {
 }
 
 // stream processes a stream of DiscoveryRequests.
+//
+// Each response carries the version of the resource's cache from which it was
+// generated, and a nonce unique to this stream. Envoy replies to each response
+// with a request carrying that nonce; if Envoy rejected the response the request
+// also carries an ErrorDetail. Either way the stream then waits for the cache
+// to change before responding again, so a rejected configuration is not resent.
+// Changes to the cache which leave the stream's filtered resources unchanged are
+// not sent.
 func (xh *xdsHandler) stream(st grpcStream) (err error) {
 	// bump connection counter and set it as a field on the logger
 	log := xh.WithField("connection", xh.connections.next())
 
+	// typeURL is the type of the first request on this stream,
+	// it is recorded as the type of the stream.
+	var typeURL string
+
 	// set up some nice function exit handling which notifies if the
 	// stream terminated on error or not.
 	defer func() {
+		if typeURL != "" {
+			xh.metrics.StreamClosed(typeURL)
+		}
 		if err != nil {
 			log.WithError(err).Error("stream terminated")
 		} else {
@@ -70,9 +125,21 @@ func (xh *xdsHandler) stream(st grpcStream) (err error) {
 	}()
 
 	ch := make(chan int, 1)
+
+	// last is the version of the cache last sent on this stream, nonce
+	// is the nonce of that response, and hash is the hash of its resources.
+	// All are zero until the first response is sent.
 	last := 0
+	var nonce counter
+	var hash uint64
 	ctx := st.Context()
 
+	// hold back the first response until the caches are complete,
+	// otherwise Envoy may be sent an empty configuration.
+	if err := xh.waitReady(ctx, log); err != nil {
+		return err
+	}
+
 	// now stick in this loop until the client disconnects.
 	for {
 		// first we wait for the request from Envoy, this is part of
@@ -88,11 +155,20 @@ func (xh *xdsHandler) stream(st grpcStream) (err error) {
 		if !ok {
 			return fmt.Errorf("no resource registered for typeURL %q", req.TypeUrl)
 		}
+		if typeURL == "" {
+			typeURL = req.TypeUrl
+			xh.metrics.StreamOpened(typeURL)
+		}
 
 		// stick some debugging details on the logger, not that we redeclare log in this scope
 		// so the next time around the loop all is forgotten.
 		log := log.WithField("version_info", req.VersionInfo).WithField("resource_names", req.ResourceNames).WithField("type_url", req.TypeUrl).WithField("response_nonce", req.ResponseNonce).WithField("error_detail", req.ErrorDetail)
 
+		if !xh.reply(log, req, strconv.FormatUint(uint64(nonce), 10), last) {
+			continue
+		}
+
+	wait:
 		for {
 			log.Info("stream_wait")
 
@@ -102,8 +178,6 @@ func (xh *xdsHandler) stream(st grpcStream) (err error) {
 			select {
 			case last = <-ch:
 				// boom, something in the cache has changed.
-				// TODO(dfc) the thing that has changed may not be in the scope of the filter
-				// so we're going to be sending an update that is a no-op. See #426
 
 				// generate a filter from the request, then call toAny which
 				// will get r's (our resource) filter values, then convert them
@@ -113,16 +187,28 @@ func (xh *xdsHandler) stream(st grpcStream) (err error) {
 					return err
 				}
 
+				// the thing that has changed may not be in the scope of the
+				// filter, if so there is nothing to send. See #426
+				h := hashAny(resources)
+				if nonce > 0 && h == hash {
+					log.WithField("version", last).Debug("unchanged")
+					continue
+				}
+
 				resp := &v2.DiscoveryResponse{
-					VersionInfo: "0",
+					VersionInfo: strconv.Itoa(last),
 					Resources:   resources,
 					TypeUrl:     r.TypeURL(),
-					Nonce:       "0",
+					Nonce:       strconv.FormatUint(nonce.next(), 10),
 				}
+				start := time.Now()
 				if err := st.Send(resp); err != nil {
 					return err
 				}
-				log.WithField("count", len(resources)).Info("response")
+				xh.metrics.ObserveSend(resp.TypeUrl, time.Since(start))
+				hash = h
+				log.WithField("count", len(resources)).WithField("version", resp.VersionInfo).WithField("nonce", resp.Nonce).Info("response")
+				break wait
 
 				// ok, the client hung up, return any error stored in the context and we're done.
 			case <-ctx.Done():
@@ -147,6 +233,42 @@ func toAny(res resource, filter func(string) bool) ([]types.Any, error) {
 	return resources, nil
 }
 
+// reply inspects req, Envoy's reply to the response with the supplied nonce and
+// version, logging whether that response was accepted or rejected. reply returns
+// false if req is a reply to a response other than the one most recently sent.
+func (xh *xdsHandler) reply(log logrus.FieldLogger, req *v2.DiscoveryRequest, nonce string, version int) bool {
+	switch {
+	case req.ResponseNonce == "":
+		// the initial request for this type on this stream.
+	case req.ResponseNonce != nonce:
+		// this request refers to a response other than the one
+		// most recently sent, a later request will supersede it.
+		log.Info("stale nonce")
+		return false
+	case req.ErrorDetail != nil:
+		// Envoy rejected the last response, it will continue to use
+		// req.VersionInfo, the last version it accepted.
+		xh.metrics.Nack(req.TypeUrl)
+		log.WithField("rejected_version", strconv.Itoa(version)).Error("nack")
+	default:
+		log.Info("ack")
+	}
+	return true
+}
+
+// hashAny returns a hash of the type and contents of resources.
+func hashAny(resources []types.Any) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, r := range resources {
+		binary.BigEndian.PutUint64(buf[:], uint64(len(r.Value)))
+		h.Write([]byte(r.TypeUrl))
+		h.Write(buf[:])
+		h.Write(r.Value)
+	}
+	return h.Sum64()
+}
+
 // toFilter converts a slice of strings into a filter function.
 // If the slice is empty, then a filter function that matches everything
 // is returned.
@@ -21,7 +21,13 @@ import (
 	"testing"
 
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	google_rpc "github.com/gogo/googleapis/google/rpc"
 	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/heptio/contour/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestXDSHandlerFetch(t *testing.T) {
@@ -51,6 +57,14 @@ func TestXDSHandlerFetch(t *testing.T) {
 			req:  &v2.DiscoveryRequest{TypeUrl: "com.heptio.potato"},
 			want: fmt.Errorf("proto: Marshal called with nil"),
 		},
+		"caches not synced": {
+			xh: xdsHandler{
+				FieldLogger: log,
+				ready:       make(chan struct{}),
+			},
+			req:  &v2.DiscoveryRequest{TypeUrl: "com.heptio.potato"},
+			want: status.Error(codes.Unavailable, "caches not synced"),
+		},
 	}
 
 	for name, tc := range tests {
@@ -173,6 +187,29 @@ func TestXDSHandlerStream(t *testing.T) {
 			},
 			want: fmt.Errorf("context canceled"),
 		},
+		"caches not synced": {
+			xh: xdsHandler{
+				FieldLogger: log,
+				ready:       make(chan struct{}),
+			},
+			stream: &mockStream{
+				context: func() context.Context {
+					ctx := context.Background()
+					ctx, cancel := context.WithCancel(ctx)
+					cancel()
+					return ctx
+				},
+				recv: func() (*v2.DiscoveryRequest, error) {
+					return &v2.DiscoveryRequest{
+						TypeUrl: "com.heptio.potato",
+					}, nil
+				},
+				send: func(resp *v2.DiscoveryResponse) error {
+					return io.EOF
+				},
+			},
+			want: context.Canceled,
+		},
 	}
 
 	for name, tc := range tests {
@@ -185,6 +222,196 @@ func TestXDSHandlerStream(t *testing.T) {
 	}
 }
 
+func TestXDSHandlerStreamAckNack(t *testing.T) {
+	tests := map[string]struct {
+		reqs      []*v2.DiscoveryRequest
+		wantNacks uint64
+	}{
+		"ack": {
+			reqs: []*v2.DiscoveryRequest{{
+				TypeUrl: "com.heptio.potato",
+			}, {
+				TypeUrl:       "com.heptio.potato",
+				VersionInfo:   "1",
+				ResponseNonce: "1",
+			}},
+			wantNacks: 0,
+		},
+		"nack": {
+			reqs: []*v2.DiscoveryRequest{{
+				TypeUrl: "com.heptio.potato",
+			}, {
+				TypeUrl:       "com.heptio.potato",
+				ResponseNonce: "1",
+				ErrorDetail:   &google_rpc.Status{Message: "rejected"},
+			}},
+			wantNacks: 1,
+		},
+		"stale nonce": {
+			reqs: []*v2.DiscoveryRequest{{
+				TypeUrl: "com.heptio.potato",
+			}, {
+				TypeUrl:       "com.heptio.potato",
+				ResponseNonce: "7",
+				ErrorDetail:   &google_rpc.Status{Message: "rejected"},
+			}},
+			wantNacks: 0,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			xh := xdsHandler{
+				FieldLogger: testLogger(t),
+				metrics:     metrics.NewMetrics(registry),
+				resources: map[string]resource{
+					"com.heptio.potato": &mockResource{
+						register: func(ch chan int, last int) {
+							// the cache is at version 1 and never changes.
+							if last < 1 {
+								ch <- 1
+							}
+						},
+						values: func(fn func(string) bool) []proto.Message {
+							return []proto.Message{new(v2.ClusterLoadAssignment)}
+						},
+						typeurl: func() string { return "com.heptio.potato" },
+					},
+				},
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			var sent []*v2.DiscoveryResponse
+			reqs := tc.reqs
+			st := &mockStream{
+				context: func() context.Context { return ctx },
+				recv: func() (*v2.DiscoveryRequest, error) {
+					if len(reqs) == 0 {
+						// the stream is waiting on a change to the
+						// cache which will never come.
+						cancel()
+						return nil, io.EOF
+					}
+					req := reqs[0]
+					reqs = reqs[1:]
+					if len(reqs) == 0 {
+						cancel()
+					}
+					return req, nil
+				},
+				send: func(resp *v2.DiscoveryResponse) error {
+					sent = append(sent, resp)
+					return nil
+				},
+			}
+
+			err := xh.stream(st)
+			if err != context.Canceled && err != io.EOF {
+				t.Fatalf("expected: %v, got: %v", context.Canceled, err)
+			}
+
+			// exactly one response is sent, the reply to it must
+			// not cause the same version to be sent again.
+			if len(sent) != 1 {
+				t.Fatalf("expected 1 response, got %d", len(sent))
+			}
+			if got := sent[0]; got.VersionInfo != "1" || got.Nonce != "1" {
+				t.Fatalf("expected version %q nonce %q, got version %q nonce %q", "1", "1", got.VersionInfo, got.Nonce)
+			}
+			if got := counterValue(t, registry, "contour_xds_nacks_total"); got != float64(tc.wantNacks) {
+				t.Fatalf("expected %d nacks, got %v", tc.wantNacks, got)
+			}
+		})
+	}
+}
+
+func TestXDSHandlerStreamUnchanged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	xh := xdsHandler{
+		FieldLogger: testLogger(t),
+		resources: map[string]resource{
+			"com.heptio.potato": &mockResource{
+				register: func(ch chan int, last int) {
+					// the cache changes twice more, but the
+					// values returned do not.
+					if last < 3 {
+						ch <- last + 1
+						return
+					}
+					cancel()
+				},
+				values: func(fn func(string) bool) []proto.Message {
+					return []proto.Message{&v2.ClusterLoadAssignment{ClusterName: "potato"}}
+				},
+				typeurl: func() string { return "com.heptio.potato" },
+			},
+		},
+	}
+	reqs := []*v2.DiscoveryRequest{{
+		TypeUrl: "com.heptio.potato",
+	}, {
+		TypeUrl:       "com.heptio.potato",
+		VersionInfo:   "1",
+		ResponseNonce: "1",
+	}}
+	var sent []*v2.DiscoveryResponse
+	st := &mockStream{
+		context: func() context.Context { return ctx },
+		recv: func() (*v2.DiscoveryRequest, error) {
+			if len(reqs) == 0 {
+				return nil, io.EOF
+			}
+			req := reqs[0]
+			reqs = reqs[1:]
+			return req, nil
+		},
+		send: func(resp *v2.DiscoveryResponse) error {
+			sent = append(sent, resp)
+			return nil
+		},
+	}
+
+	if err := xh.stream(st); err != context.Canceled {
+		t.Fatalf("expected: %v, got: %v", context.Canceled, err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 response, got %d", len(sent))
+	}
+}
+
+func TestHashAny(t *testing.T) {
+	a := []types.Any{{TypeUrl: "com.heptio.potato", Value: []byte("ab")}, {TypeUrl: "com.heptio.potato", Value: []byte("c")}}
+	b := []types.Any{{TypeUrl: "com.heptio.potato", Value: []byte("a")}, {TypeUrl: "com.heptio.potato", Value: []byte("bc")}}
+	if hashAny(a) != hashAny(a) {
+		t.Fatalf("expected hash of %v to be stable", a)
+	}
+	if hashAny(a) == hashAny(b) {
+		t.Fatalf("expected hash of %v and %v to differ", a, b)
+	}
+	if hashAny(nil) == hashAny(a[:1]) {
+		t.Fatalf("expected hash of nil and %v to differ", a[:1])
+	}
+}
+
+// counterValue returns the value of the named counter
+// in registry, summed across all its labels.
+func counterValue(t *testing.T, registry *prometheus.Registry, name string) float64 {
+	t.Helper()
+	mfs, err := registry.Gather()
+	check(t, err)
+	var v float64
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			v += m.GetCounter().GetValue()
+		}
+	}
+	return v
+}
+
 type mockStream struct {
 	context func() context.Context
 	send    func(*v2.DiscoveryResponse) error
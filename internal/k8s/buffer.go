@@ -20,7 +20,9 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
-type buffer struct {
+// A Buffer is a ResourceEventHandler which buffers and serialises
+// ResourceEventHandler events.
+type Buffer struct {
 	ev chan interface{}
 	logrus.StdLogger
 	rh cache.ResourceEventHandler
@@ -38,9 +40,12 @@ type deleteEvent struct {
 	obj interface{}
 }
 
-// NewBuffer returns a ResourceEventHandler which buffers and serialises ResourceEventHandler events.
-func NewBuffer(g *workgroup.Group, rh cache.ResourceEventHandler, log logrus.FieldLogger, size int) cache.ResourceEventHandler {
-	buf := &buffer{
+// funcEvent is called when the events buffered before it have been delivered.
+type funcEvent func()
+
+// NewBuffer returns a Buffer which delivers events to rh.
+func NewBuffer(g *workgroup.Group, rh cache.ResourceEventHandler, log logrus.FieldLogger, size int) *Buffer {
+	buf := &Buffer{
 		ev:        make(chan interface{}, size),
 		StdLogger: log.WithField("context", "buffer"),
 		rh:        rh,
@@ -52,7 +57,7 @@ func NewBuffer(g *workgroup.Group, rh cache.ResourceEventHandler, log logrus.Fie
 	return buf
 }
 
-func (b *buffer) loop(stop <-chan struct{}) {
+func (b *Buffer) loop(stop <-chan struct{}) {
 	b.Println("started")
 	defer b.Println("stopped")
 
@@ -66,6 +71,8 @@ func (b *buffer) loop(stop <-chan struct{}) {
 				b.rh.OnUpdate(ev.oldObj, ev.newObj)
 			case *deleteEvent:
 				b.rh.OnDelete(ev.obj)
+			case funcEvent:
+				ev()
 			default:
 				b.Printf("unhandled event type: %T: %v", ev, ev)
 			}
@@ -75,19 +82,19 @@ func (b *buffer) loop(stop <-chan struct{}) {
 	}
 }
 
-func (b *buffer) OnAdd(obj interface{}) {
+func (b *Buffer) OnAdd(obj interface{}) {
 	b.send(&addEvent{obj})
 }
 
-func (b *buffer) OnUpdate(oldObj, newObj interface{}) {
+func (b *Buffer) OnUpdate(oldObj, newObj interface{}) {
 	b.send(&updateEvent{oldObj, newObj})
 }
 
-func (b *buffer) OnDelete(obj interface{}) {
+func (b *Buffer) OnDelete(obj interface{}) {
 	b.send(&deleteEvent{obj})
 }
 
-func (b *buffer) send(ev interface{}) {
+func (b *Buffer) send(ev interface{}) {
 	select {
 	case b.ev <- ev:
 		// all good
@@ -96,3 +103,30 @@ func (b *buffer) send(ev interface{}) {
 		b.ev <- ev
 	}
 }
+
+// Do calls fn on the goroutine which delivers events to the
+// ResourceEventHandler, once the events buffered before Do was
+// called have been delivered.
+func (b *Buffer) Do(fn func()) {
+	b.send(funcEvent(fn))
+}
+
+// Sync blocks until the events buffered before Sync was called
+// have been delivered, or stop is closed.
+func (b *Buffer) Sync(stop <-chan struct{}) {
+	done := make(chan struct{})
+	select {
+	case b.ev <- funcEvent(func() { close(done) }):
+	case <-stop:
+		return
+	}
+	select {
+	case <-done:
+	case <-stop:
+	}
+}
+
+// Len returns the number of events waiting to be delivered.
+func (b *Buffer) Len() int {
+	return len(b.ev)
+}
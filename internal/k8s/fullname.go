@@ -0,0 +1,38 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FullName holds the name and namespace of a Kubernetes object.
+// The zero value names no object.
+type FullName struct {
+	Name, Namespace string
+}
+
+// ParseFullName parses s, of the form namespace/name, into a FullName.
+func ParseFullName(s string) (FullName, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return FullName{}, fmt.Errorf("%q must be of the form namespace/name", s)
+	}
+	return FullName{Name: parts[1], Namespace: parts[0]}, nil
+}
+
+func (n FullName) String() string {
+	return n.Namespace + "/" + n.Name
+}
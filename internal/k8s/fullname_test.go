@@ -0,0 +1,41 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import "testing"
+
+func TestParseFullName(t *testing.T) {
+	tests := map[string]struct {
+		want FullName
+		err  bool
+	}{
+		"default/secret": {want: FullName{Name: "secret", Namespace: "default"}},
+		"secret":         {err: true},
+		"/secret":        {err: true},
+		"default/":       {err: true},
+		"":               {err: true},
+	}
+
+	for s, tc := range tests {
+		t.Run(s, func(t *testing.T) {
+			got, err := ParseFullName(s)
+			if tc.err != (err != nil) {
+				t.Fatalf("expected error: %v, got: %v", tc.err, err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected: %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
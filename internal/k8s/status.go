@@ -0,0 +1,66 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"reflect"
+
+	ingressroutev1 "github.com/heptio/contour/apis/contour/v1beta1"
+	clientset "github.com/heptio/contour/internal/generated/clientset/versioned"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// IngressRouteStatus writes the Status of IngressRoute objects
+// back to the API server.
+type IngressRouteStatus struct {
+	Client clientset.Interface
+}
+
+// SetStatus sets the status of the supplied IngressRoute, its current
+// status, description, and load balancer status, to status in a single
+// update. If the IngressRoute already carries this status, no update
+// is performed.
+func (irs *IngressRouteStatus) SetStatus(status ingressroutev1.Status, existing *ingressroutev1.IngressRoute) error {
+	if reflect.DeepEqual(existing.Status, status) {
+		// status is unchanged, avoid a round trip to the API server.
+		return nil
+	}
+
+	// existing is owned by the informer's cache, so it must not be modified.
+	updated := existing.DeepCopy()
+	updated.Status = status
+	_, err := irs.Client.ContourV1beta1().IngressRoutes(existing.Namespace).UpdateStatus(updated)
+	return err
+}
+
+// IngressStatus writes the Status of Ingress objects back to the API server.
+type IngressStatus struct {
+	Client kubernetes.Interface
+}
+
+// SetLoadBalancer sets the load balancer status of the supplied Ingress to lb.
+// If the Ingress already carries this status, no update is performed.
+func (is *IngressStatus) SetLoadBalancer(lb v1.LoadBalancerStatus, existing *v1beta1.Ingress) error {
+	if reflect.DeepEqual(existing.Status.LoadBalancer, lb) {
+		return nil
+	}
+	// existing is owned by the informer's cache, so it must not be modified.
+	updated := existing.DeepCopy()
+	updated.Status.LoadBalancer = lb
+	_, err := is.Client.ExtensionsV1beta1().Ingresses(existing.Namespace).UpdateStatus(updated)
+	return err
+}
@@ -24,48 +24,86 @@ import (
 
 	"k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
-	"k8s.io/apimachinery/pkg/fields"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 )
 
 // WatchServices creates a SharedInformer for v1.Services and registers it with g.
-func WatchServices(g *workgroup.Group, client *kubernetes.Clientset, log logrus.FieldLogger, rs ...cache.ResourceEventHandler) {
-	watch(g, client.CoreV1().RESTClient(), log, "services", new(v1.Service), rs...)
+func WatchServices(g *workgroup.Group, client *kubernetes.Clientset, log logrus.FieldLogger, namespaces []string, rs ...cache.ResourceEventHandler) Informers {
+	return watch(g, client.CoreV1().RESTClient(), log, "services", new(v1.Service), namespaces, rs...)
 }
 
 // WatchEndpoints creates a SharedInformer for v1.Endpoints and registers it with g.
-func WatchEndpoints(g *workgroup.Group, client *kubernetes.Clientset, log logrus.FieldLogger, rs ...cache.ResourceEventHandler) {
-	watch(g, client.CoreV1().RESTClient(), log, "endpoints", new(v1.Endpoints), rs...)
+func WatchEndpoints(g *workgroup.Group, client *kubernetes.Clientset, log logrus.FieldLogger, namespaces []string, rs ...cache.ResourceEventHandler) Informers {
+	return watch(g, client.CoreV1().RESTClient(), log, "endpoints", new(v1.Endpoints), namespaces, rs...)
 }
 
 // WatchIngress creates a SharedInformer for v1beta1.Ingress and registers it with g.
-func WatchIngress(g *workgroup.Group, client *kubernetes.Clientset, log logrus.FieldLogger, rs ...cache.ResourceEventHandler) {
-	watch(g, client.ExtensionsV1beta1().RESTClient(), log, "ingresses", new(v1beta1.Ingress), rs...)
+func WatchIngress(g *workgroup.Group, client *kubernetes.Clientset, log logrus.FieldLogger, namespaces []string, rs ...cache.ResourceEventHandler) Informers {
+	return watch(g, client.ExtensionsV1beta1().RESTClient(), log, "ingresses", new(v1beta1.Ingress), namespaces, rs...)
 }
 
 // WatchSecrets creates a SharedInformer for v1.Secrets and registers it with g.
-func WatchSecrets(g *workgroup.Group, client *kubernetes.Clientset, log logrus.FieldLogger, rs ...cache.ResourceEventHandler) {
-	watch(g, client.CoreV1().RESTClient(), log, "secrets", new(v1.Secret), rs...)
+// If set, only Secrets matching labelSelector and fieldSelector are watched.
+func WatchSecrets(g *workgroup.Group, client *kubernetes.Clientset, log logrus.FieldLogger, namespaces []string, labelSelector, fieldSelector string, rs ...cache.ResourceEventHandler) Informers {
+	return watchSelected(g, client.CoreV1().RESTClient(), log, "secrets", new(v1.Secret), namespaces, func(options *metav1.ListOptions) {
+		options.LabelSelector = labelSelector
+		options.FieldSelector = fieldSelector
+	}, rs...)
 }
 
 // WatchIngressRoutes creates a SharedInformer for contour.heptio.com/v1.IngressRoutes and registers it with g.
-func WatchIngressRoutes(g *workgroup.Group, client *clientset.Clientset, log logrus.FieldLogger, rs ...cache.ResourceEventHandler) {
-	watch(g, client.ContourV1beta1().RESTClient(), log, ingressroutev1.ResourcePlural, new(ingressroutev1.IngressRoute), rs...)
+func WatchIngressRoutes(g *workgroup.Group, client *clientset.Clientset, log logrus.FieldLogger, namespaces []string, rs ...cache.ResourceEventHandler) Informers {
+	return watch(g, client.ContourV1beta1().RESTClient(), log, ingressroutev1.ResourcePlural, new(ingressroutev1.IngressRoute), namespaces, rs...)
 }
 
-func watch(g *workgroup.Group, c cache.Getter, log logrus.FieldLogger, resource string, objType runtime.Object, rs ...cache.ResourceEventHandler) {
-	lw := cache.NewListWatchFromClient(c, resource, v1.NamespaceAll, fields.Everything())
-	sw := cache.NewSharedInformer(lw, objType, time.Duration(0)) // resync timer disabled
-	for _, r := range rs {
-		sw.AddEventHandler(r)
+// Informers holds the shared informers, one per watched
+// namespace, for a resource.
+type Informers []cache.SharedInformer
+
+// HasSynced returns true if every informer has completed its initial list.
+func (i Informers) HasSynced() bool {
+	for _, sw := range i {
+		if !sw.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// watch adds a shared informer for resource in each of namespaces,
+// or in all namespaces if namespaces is empty, to g.
+func watch(g *workgroup.Group, c cache.Getter, log logrus.FieldLogger, resource string, objType runtime.Object, namespaces []string, rs ...cache.ResourceEventHandler) Informers {
+	return watchSelected(g, c, log, resource, objType, namespaces, func(*metav1.ListOptions) {}, rs...)
+}
+
+// watchSelected is like watch, but options modifies the options
+// of each list and watch request, for example to add selectors.
+func watchSelected(g *workgroup.Group, c cache.Getter, log logrus.FieldLogger, resource string, objType runtime.Object, namespaces []string, options func(*metav1.ListOptions), rs ...cache.ResourceEventHandler) Informers {
+	if len(namespaces) == 0 {
+		namespaces = []string{v1.NamespaceAll}
+	}
+	var informers Informers
+	for _, ns := range namespaces {
+		ns := ns
+		lw := cache.NewFilteredListWatchFromClient(c, resource, ns, options)
+		sw := cache.NewSharedInformer(lw, objType, time.Duration(0)) // resync timer disabled
+		for _, r := range rs {
+			sw.AddEventHandler(r)
+		}
+		g.Add(func(stop <-chan struct{}) error {
+			log := log.WithField("resource", resource)
+			if ns != v1.NamespaceAll {
+				log = log.WithField("namespace", ns)
+			}
+			log.Println("started")
+			defer log.Println("stopped")
+			sw.Run(stop)
+			return nil
+		})
+		informers = append(informers, sw)
 	}
-	g.Add(func(stop <-chan struct{}) error {
-		log := log.WithField("resource", resource)
-		log.Println("started")
-		defer log.Println("stopped")
-		sw.Run(stop)
-		return nil
-	})
+	return informers
 }
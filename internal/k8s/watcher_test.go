@@ -0,0 +1,164 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heptio/workgroup"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestWatchNamespaces(t *testing.T) {
+	tests := map[string]struct {
+		namespaces []string
+		want       []string
+	}{
+		"all namespaces": {
+			namespaces: nil,
+			want:       []string{"/api/v1/services"},
+		},
+		"one namespace": {
+			namespaces: []string{"default"},
+			want:       []string{"/api/v1/namespaces/default/services"},
+		},
+		"several namespaces": {
+			namespaces: []string{"tenant-a", "tenant-b"},
+			want: []string{
+				"/api/v1/namespaces/tenant-a/services",
+				"/api/v1/namespaces/tenant-b/services",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := lists(t, "ServiceList", func(g *workgroup.Group, client *kubernetes.Clientset, log logrus.FieldLogger) Informers {
+				return WatchServices(g, client, log, tc.namespaces)
+			})
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("expected: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWatchSecretsSelectors(t *testing.T) {
+	tests := map[string]struct {
+		labelSelector, fieldSelector string
+		want                         []string
+	}{
+		"no selectors": {
+			want: []string{"/api/v1/namespaces/default/secrets"},
+		},
+		"label selector": {
+			labelSelector: "contour.heptio.com/tls=true",
+			want:          []string{"/api/v1/namespaces/default/secrets?labelSelector=contour.heptio.com%2Ftls%3Dtrue"},
+		},
+		"field selector": {
+			fieldSelector: "type=kubernetes.io/tls",
+			want:          []string{"/api/v1/namespaces/default/secrets?fieldSelector=type%3Dkubernetes.io%2Ftls"},
+		},
+		"label and field selectors": {
+			labelSelector: "contour.heptio.com/tls=true",
+			fieldSelector: "type=kubernetes.io/tls",
+			want:          []string{"/api/v1/namespaces/default/secrets?fieldSelector=type%3Dkubernetes.io%2Ftls&labelSelector=contour.heptio.com%2Ftls%3Dtrue"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := lists(t, "SecretList", func(g *workgroup.Group, client *kubernetes.Clientset, log logrus.FieldLogger) Informers {
+				return WatchSecrets(g, client, log, []string{"default"}, tc.labelSelector, tc.fieldSelector)
+			})
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Fatalf("expected: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// lists starts the informers returned by watch against a fake API
+// server, which answers every list with an empty kind, and returns
+// the sorted URLs of the lists made until the informers synced.
+func lists(t *testing.T, kind string, watch func(*workgroup.Group, *kubernetes.Clientset, logrus.FieldLogger) Informers) []string {
+	var mu sync.Mutex
+	var urls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "true" {
+			// hold the watch open until the informer stops.
+			<-r.Context().Done()
+			return
+		}
+		mu.Lock()
+		u := r.URL.Path
+		q := r.URL.Query()
+		q.Del("limit")
+		q.Del("resourceVersion")
+		if len(q) > 0 {
+			u += "?" + q.Encode()
+		}
+		urls = append(urls, u)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"kind":%q,"apiVersion":"v1","metadata":{"resourceVersion":"1"},"items":[]}`, kind)
+	}))
+	defer srv.Close()
+
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := logrus.New()
+	log.Out = ioutil.Discard
+
+	var g workgroup.Group
+	informers := watch(&g, client, log)
+	stop := make(chan struct{})
+	g.Add(func(<-chan struct{}) error {
+		<-stop
+		return nil
+	})
+	done := make(chan struct{})
+	go func() {
+		g.Run()
+		close(done)
+	}()
+
+	timeout := make(chan struct{})
+	timer := time.AfterFunc(5*time.Second, func() { close(timeout) })
+	defer timer.Stop()
+	synced := cache.WaitForCacheSync(timeout, informers.HasSynced)
+	close(stop)
+	<-done
+	if !synced {
+		t.Fatal("timed out waiting for the informers to sync")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(urls)
+	return urls
+}
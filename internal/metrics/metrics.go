@@ -0,0 +1,177 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics provides Prometheus metrics for Contour.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/heptio/contour/internal/httpsvc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics records the activity of Contour's xDS server and translator.
+// The methods of a nil *Metrics do nothing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	xdsStreams         *prometheus.GaugeVec
+	xdsSendDuration    *prometheus.HistogramVec
+	xdsNacks           *prometheus.CounterVec
+	translatorDuration prometheus.Histogram
+}
+
+// NewMetrics returns a *Metrics whose metrics are registered with registry.
+func NewMetrics(registry *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		registry: registry,
+		xdsStreams: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "contour_xds_streams",
+			Help: "Number of open xDS streams by type URL.",
+		}, []string{"type_url"}),
+		xdsSendDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "contour_xds_send_duration_seconds",
+			Help: "Time taken to send an xDS response by type URL.",
+		}, []string{"type_url"}),
+		xdsNacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "contour_xds_nacks_total",
+			Help: "Total xDS responses rejected by Envoy by type URL.",
+		}, []string{"type_url"}),
+		translatorDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "contour_translation_duration_seconds",
+			Help: "Time taken to translate Kubernetes objects into Envoy configuration.",
+		}),
+	}
+	registry.MustRegister(m.xdsStreams, m.xdsSendDuration, m.xdsNacks, m.translatorDuration)
+	return m
+}
+
+// StreamOpened records that an xDS stream of typeURL was opened.
+func (m *Metrics) StreamOpened(typeURL string) {
+	if m == nil {
+		return
+	}
+	m.xdsStreams.WithLabelValues(typeURL).Inc()
+}
+
+// StreamClosed records that an xDS stream of typeURL was closed.
+func (m *Metrics) StreamClosed(typeURL string) {
+	if m == nil {
+		return
+	}
+	m.xdsStreams.WithLabelValues(typeURL).Dec()
+}
+
+// ObserveSend records the time taken to send an xDS response of typeURL.
+func (m *Metrics) ObserveSend(typeURL string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.xdsSendDuration.WithLabelValues(typeURL).Observe(d.Seconds())
+}
+
+// Nack records that Envoy rejected an xDS response of typeURL.
+func (m *Metrics) Nack(typeURL string) {
+	if m == nil {
+		return
+	}
+	m.xdsNacks.WithLabelValues(typeURL).Inc()
+}
+
+// ObserveTranslation records the time taken to translate
+// Kubernetes objects into Envoy configuration.
+func (m *Metrics) ObserveTranslation(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.translatorDuration.Observe(d.Seconds())
+}
+
+// CacheResources registers a gauge reporting the number
+// of resources, as returned by fn, in the named cache.
+func (m *Metrics) CacheResources(cache string, fn func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "contour_cache_resources",
+		Help:        "Number of resources in each cache.",
+		ConstLabels: prometheus.Labels{"cache": cache},
+	}, func() float64 { return float64(fn()) }))
+}
+
+// CacheNotifications registers a counter reporting the number of
+// times, as returned by fn, the named cache has notified its watchers.
+func (m *Metrics) CacheNotifications(cache string, fn func() int) {
+	m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name:        "contour_cache_notifications_total",
+		Help:        "Total notifications sent to the watchers of each cache.",
+		ConstLabels: prometheus.Labels{"cache": cache},
+	}, func() float64 { return float64(fn()) }))
+}
+
+// QueueDepth registers a gauge reporting the number of
+// Kubernetes events, as returned by fn, awaiting translation.
+func (m *Metrics) QueueDepth(fn func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "contour_buffer_queue_depth",
+		Help: "Number of Kubernetes events awaiting translation.",
+	}, func() float64 { return float64(fn()) }))
+}
+
+// Service serves the metrics registered with Registry in the
+// Prometheus format at /metrics, and the /healthz and /readyz probes.
+type Service struct {
+	httpsvc.Service
+
+	Registry *prometheus.Registry
+
+	// Ready, if set, is closed when Contour's caches are synced
+	// with the API server. Until then /readyz reports 503.
+	Ready <-chan struct{}
+}
+
+// Start fulfills the g.Start contract.
+// When stop is closed the http server will shutdown.
+func (svc *Service) Start(stop <-chan struct{}) error {
+	registerHealthCheck(&svc.ServeMux, svc.Ready)
+	registerMetrics(&svc.ServeMux, svc.Registry)
+	return svc.Service.Start(stop)
+}
+
+// registerMetrics registers the Prometheus handler for
+// the metrics registered with registry at /metrics on mux.
+func registerMetrics(mux *http.ServeMux, registry *prometheus.Registry) {
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+// registerHealthCheck registers the /healthz probe, which always
+// reports ok, and the /readyz probe, which reports 503 until ready,
+// if not nil, is closed, on mux.
+func registerHealthCheck(mux *http.ServeMux, ready <-chan struct{}) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if ready != nil {
+			select {
+			case <-ready:
+			default:
+				http.Error(w, "caches not synced", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
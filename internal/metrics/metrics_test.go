@@ -0,0 +1,120 @@
+// Copyright © 2018 Heptio
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := NewMetrics(registry)
+	m.StreamOpened("cds")
+	m.StreamOpened("cds")
+	m.StreamClosed("cds")
+	m.Nack("cds")
+	m.ObserveSend("cds", time.Millisecond)
+	m.ObserveTranslation(time.Millisecond)
+	m.CacheResources("cluster", func() int { return 3 })
+	m.CacheNotifications("cluster", func() int { return 7 })
+	m.QueueDepth(func() int { return 2 })
+
+	want := map[string]float64{
+		"contour_xds_streams":               1,
+		"contour_xds_nacks_total":           1,
+		"contour_cache_resources":           3,
+		"contour_cache_notifications_total": 7,
+		"contour_buffer_queue_depth":        2,
+	}
+	got := gather(t, registry)
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want: %v, got: %v", want, got)
+	}
+}
+
+func TestMetricsNil(t *testing.T) {
+	// the methods of a nil *Metrics do nothing.
+	var m *Metrics
+	m.StreamOpened("cds")
+	m.StreamClosed("cds")
+	m.Nack("cds")
+	m.ObserveSend("cds", time.Millisecond)
+	m.ObserveTranslation(time.Millisecond)
+}
+
+func TestServiceMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	NewMetrics(registry).StreamOpened("cds")
+	var mux http.ServeMux
+	registerMetrics(&mux, registry)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected: %d, got: %d", http.StatusOK, rec.Code)
+	}
+	if want := `contour_xds_streams{type_url="cds"} 1`; !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("expected %q in:\n%s", want, rec.Body.String())
+	}
+}
+
+func TestServiceHealthCheck(t *testing.T) {
+	ready := make(chan struct{})
+	var mux http.ServeMux
+	registerHealthCheck(&mux, ready)
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Code
+	}
+	if got := get("/healthz"); got != http.StatusOK {
+		t.Fatalf("/healthz: expected: %d, got: %d", http.StatusOK, got)
+	}
+	if got := get("/readyz"); got != http.StatusServiceUnavailable {
+		t.Fatalf("/readyz before ready: expected: %d, got: %d", http.StatusServiceUnavailable, got)
+	}
+
+	close(ready)
+	if got := get("/readyz"); got != http.StatusOK {
+		t.Fatalf("/readyz once ready: expected: %d, got: %d", http.StatusOK, got)
+	}
+}
+
+// gather returns the value of each gauge and counter in registry.
+func gather(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]float64)
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			switch {
+			case m.Gauge != nil:
+				values[mf.GetName()] += m.GetGauge().GetValue()
+			case m.Counter != nil:
+				values[mf.GetName()] += m.GetCounter().GetValue()
+			}
+		}
+	}
+	return values
+}